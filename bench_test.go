@@ -30,6 +30,17 @@ func EncodeBenchmarkCommon(b *testing.B, ob interface{}) {
 		}
 	})
 
+	b.Run("XDRMarshalAppend", func(b *testing.B) {
+		var buf []byte
+		for i := 0; i < b.N; i++ {
+			var err error
+			buf, err = MarshalAppend(buf[:0], ob)
+			if err != nil {
+				b.Fatalf("MarshalAppend: %s", err)
+			}
+		}
+	})
+
 	b.Run("XDRWriteDiscard", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			err := Write(ioutil.Discard, ob)
@@ -4,10 +4,18 @@
 package xdr
 
 import (
+	"bytes"
+	stderrors "errors"
+	"io"
+	"io/ioutil"
 	"math"
+	"math/big"
+	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.e43.eu/xdr/internal/errors"
 )
 
@@ -493,3 +501,1545 @@ func TestCodecsBasic(t *testing.T) {
 
 	RunTestcases(t, testcases)
 }
+
+func TestUnmarshalNoCopy(t *testing.T) {
+	type s struct {
+		O  []byte  `xdr:"opaque"`
+		S  string  `xdr:"maxlen:8"`
+		FS string  `xdr:"len:3"`
+		FO [2]byte `xdr:"opaque"`
+	}
+
+	buf := []byte{
+		0, 0, 0, 3, 'a', 'b', 'c', 0,
+		0, 0, 0, 2, 'h', 'i', 0, 0,
+		'x', 'y', 'z', 0,
+		0xA, 0xB, 0, 0,
+	}
+
+	var v s
+	require.NoError(t, UnmarshalNoCopy(buf, &v))
+	assert.Equal(t, s{O: []byte("abc"), S: "hi", FS: "xyz", FO: [2]byte{0xA, 0xB}}, v)
+
+	// The opaque must alias the input, and must not be able to grow into it
+	assert.True(t, &v.O[0] == &buf[4], "Opaque should alias input buffer")
+	assert.Equal(t, len(v.O), cap(v.O), "Opaque capacity should be limited")
+
+	// Copying unmarshal must not alias
+	var vc s
+	require.NoError(t, Unmarshal(buf, &vc))
+	assert.Equal(t, v, vc)
+	assert.False(t, &vc.O[0] == &buf[4], "Opaque should not alias input buffer")
+
+	// Truncated input
+	err := UnmarshalNoCopy(buf[0:6], &v)
+	assert.True(t, stderrors.Is(err, io.ErrUnexpectedEOF), "Expected io.ErrUnexpectedEOF, got %v", err)
+
+	// Truncated exactly after the length
+	err = UnmarshalNoCopy(buf[0:4], &v)
+	assert.True(t, stderrors.Is(err, io.ErrUnexpectedEOF), "Expected io.ErrUnexpectedEOF, got %v", err)
+
+	// Wherever the input is truncated, the error must match that of the copying path
+	for n := 0; n < len(buf); n++ {
+		errCopy := Unmarshal(buf[0:n], &vc)
+		errNoCopy := UnmarshalNoCopy(buf[0:n], &v)
+		if assert.Error(t, errNoCopy, "Truncated to %d", n) {
+			assert.Equal(t, errCopy.Error(), errNoCopy.Error(), "Truncated to %d", n)
+		}
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	type s struct {
+		I int32
+		S string `xdr:"maxlen:4"`
+	}
+
+	dst := make([]byte, 2, 64)
+	dst[0], dst[1] = 0xAA, 0xBB
+
+	out, err := MarshalAppend(dst, s{I: 1, S: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xAA, 0xBB, 0, 0, 0, 1, 0, 0, 0, 2, 'h', 'i', 0, 0}, out)
+	assert.True(t, &out[0] == &dst[0], "MarshalAppend should reuse dst when it has capacity")
+
+	// Growing from nil
+	out, err = MarshalAppend(nil, int32(-1))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, out)
+
+	// Errors truncate back to the original length
+	out, err = MarshalAppend(dst, s{I: 1, S: "Hello"})
+	assert.True(t, stderrors.Is(err, errors.ErrLengthExceedsMax), "Expected ErrLengthExceedsMax, got %v", err)
+	assert.Equal(t, []byte{0xAA, 0xBB}, out)
+}
+
+func TestEncodedSizeFixed(t *testing.T) {
+	type fixed struct {
+		A int32
+		B [3]byte `xdr:"opaque"`
+		C [2]complex64
+		D bool
+	}
+
+	type variable struct {
+		F fixed
+		S string
+	}
+
+	n, err := EncodedSize(fixed{})
+	require.NoError(t, err)
+	assert.Equal(t, 4+4+16+4, n)
+
+	n, err = EncodedSize(&fixed{})
+	require.NoError(t, err)
+	assert.Equal(t, 4+4+16+4, n)
+
+	n, err = EncodedSize(&variable{S: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, 28+4+8, n)
+
+	_, err = EncodedSize((*fixed)(nil))
+	assert.True(t, stderrors.Is(err, errors.ErrNilPointer), "Expected ErrNilPointer, got %v", err)
+}
+
+type limitsList struct {
+	V    int32
+	Next *limitsList `xdr:"opt"`
+}
+
+func TestDecodeLimits(t *testing.T) {
+	c := NewCoder()
+	c.SetLimits(Limits{MaxBytes: 1024, MaxElements: 16, MaxDepth: 4})
+
+	checkLimit := func(t *testing.T, err error, kind LimitKind) {
+		t.Helper()
+		require.Error(t, err)
+		assert.True(t, stderrors.Is(err, ErrLimitExceeded), "Expected ErrLimitExceeded, got %v", err)
+		var le LimitError
+		if assert.True(t, stderrors.As(err, &le), "Expected LimitError, got %v", err) {
+			assert.Equal(t, kind, le.Kind)
+		}
+	}
+
+	t.Run("Bytes", func(t *testing.T) {
+		var o struct {
+			O []byte `xdr:"opaque"`
+		}
+		// A huge length and no body: this must fail before allocating anything
+		err := c.Unmarshal([]byte{0xff, 0xff, 0xff, 0xf0}, &o)
+		checkLimit(t, err, LimitBytes)
+	})
+
+	t.Run("Elements", func(t *testing.T) {
+		var s []int32
+		err := c.Unmarshal([]byte{0, 0, 0, 17}, &s)
+		checkLimit(t, err, LimitElements)
+
+		// Within limits
+		err = c.Unmarshal([]byte{0, 0, 0, 1, 0, 0, 0, 7}, &s)
+		require.NoError(t, err)
+		assert.Equal(t, []int32{7}, s)
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		var buf []byte
+		for i := 0; i < 8; i++ {
+			buf = append(buf, 0, 0, 0, byte(i), 0, 0, 0, 1)
+		}
+		buf = append(buf, 0, 0, 0, 8, 0, 0, 0, 0)
+
+		var l limitsList
+		checkLimit(t, c.Unmarshal(buf, &l), LimitDepth)
+
+		// Per-decoder override
+		d := c.NewDecoder(bytes.NewReader(buf))
+		d.SetLimits(Limits{})
+		require.NoError(t, d.Decode(&l))
+		assert.Equal(t, int32(8), l.Next.Next.Next.Next.Next.Next.Next.Next.V)
+	})
+
+	assert.Panics(t, func() { DefaultCoder.SetLimits(Limits{}) })
+}
+
+func TestDecodeTruncatedLarge(t *testing.T) {
+	// Each of these claims a length of 256MiB (or more) but contains only 64KiB
+	// of data. Decoding must fail with io.ErrUnexpectedEOF without allocating
+	// anything like the claimed amount
+	body := make([]byte, 64<<10)
+	claim := func(l uint32) []byte {
+		return append([]byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}, body...)
+	}
+
+	testcases := []struct {
+		name string
+		buf  []byte
+		out  interface{}
+	}{
+		{"opaque", claim(256 << 20), &struct {
+			O []byte `xdr:"opaque"`
+		}{}},
+		{"string", claim(256 << 20), new(string)},
+		{"[]int32", claim(64 << 20), new([]int32)},
+		{"[]int64", claim(32 << 20), new([]int64)},
+		{"map", claim(16 << 20), new(map[int32]int64)},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			err := Read(bytes.NewReader(tc.buf), tc.out)
+			runtime.ReadMemStats(&after)
+
+			assert.True(t, stderrors.Is(err, io.ErrUnexpectedEOF), "Expected io.ErrUnexpectedEOF, got %v", err)
+			assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20), "Allocated too much memory")
+		})
+	}
+
+	// A large, complete, slice must still decode correctly
+	in := make([]int32, 100000)
+	for i := range in {
+		in[i] = int32(i)
+	}
+	buf, err := Marshal(in)
+	require.NoError(t, err)
+	var out []int32
+	require.NoError(t, Unmarshal(buf, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestStrict(t *testing.T) {
+	c := NewCoder()
+	c.SetStrict(true)
+
+	// "abc" with non-zero padding
+	badPad := []byte{0, 0, 0, 3, 'a', 'b', 'c', 1}
+
+	t.Run("Padding", func(t *testing.T) {
+		var s string
+		require.NoError(t, Unmarshal(badPad, &s))
+		assert.Equal(t, "abc", s)
+
+		assert.Equal(t, ErrNonZeroPadding, c.Unmarshal(badPad, &s))
+		assert.Equal(t, ErrNonZeroPadding, c.UnmarshalNoCopy(badPad, &s))
+
+		var o struct {
+			O []byte `xdr:"opaque"`
+		}
+		assert.True(t, stderrors.Is(c.Unmarshal(badPad, &o), ErrNonZeroPadding))
+
+		var f struct {
+			S string `xdr:"len:3"`
+		}
+		assert.True(t, stderrors.Is(c.Unmarshal(badPad[4:], &f), ErrNonZeroPadding))
+		assert.True(t, stderrors.Is(c.UnmarshalNoCopy(badPad[4:], &f), ErrNonZeroPadding))
+
+		var a struct {
+			A [3]byte `xdr:"opaque"`
+		}
+		assert.True(t, stderrors.Is(c.Unmarshal(badPad[4:], &a), ErrNonZeroPadding))
+
+		require.NoError(t, c.Unmarshal([]byte{0, 0, 0, 3, 'a', 'b', 'c', 0}, &s))
+		assert.Equal(t, "abc", s)
+	})
+
+	t.Run("OpaqueReader", func(t *testing.T) {
+		d := c.NewDecoder(bytes.NewReader(badPad))
+		_, r, err := d.OpaqueReader(16)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("abc"), body)
+		assert.Equal(t, ErrNonZeroPadding, r.Close())
+
+		// Truncated body
+		d = c.NewDecoder(bytes.NewReader(badPad[0:6]))
+		_, r, err = d.OpaqueReader(16)
+		require.NoError(t, err)
+		assert.Equal(t, io.ErrUnexpectedEOF, r.Close())
+	})
+
+	t.Run("TrailingData", func(t *testing.T) {
+		var v uint32
+		buf := []byte{0, 0, 0, 1, 0, 0, 0, 2}
+		require.NoError(t, Unmarshal(buf, &v))
+		assert.Equal(t, ErrTrailingData, c.Unmarshal(buf, &v))
+		assert.Equal(t, ErrTrailingData, c.UnmarshalNoCopy(buf, &v))
+		require.NoError(t, c.Unmarshal(buf[0:4], &v))
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		var s string
+		d := c.NewDecoder(bytes.NewReader(badPad))
+		d.SetStrict(false)
+		require.NoError(t, d.Decode(&s))
+	})
+
+	assert.Panics(t, func() { DefaultCoder.SetStrict(true) })
+}
+
+type offsetInner struct {
+	A uint32
+	B []byte `xdr:"maxlen:4/opaque"`
+}
+
+type offsetOuter struct {
+	X     uint64
+	Inner offsetInner
+}
+
+func TestErrorOffsets(t *testing.T) {
+	buf := []byte{
+		0, 0, 0, 0, 0, 0, 0, 1, // X
+		0, 0, 0, 2, // Inner.A
+		0, 0, 0, 5, // Inner.B length
+	}
+
+	var o offsetOuter
+	err := Unmarshal(buf, &o)
+	require.Error(t, err)
+	assert.True(t, stderrors.Is(err, ErrLengthExceedsMax))
+
+	var fe FieldError
+	require.True(t, stderrors.As(err, &fe))
+	assert.Equal(t, int64(12), fe.Offset)
+	assert.Equal(t, "xdr: Variable length object too long (5 > 4, offset 0xc) (at offsetOuter.Inner.B, offset 0xc)", err.Error())
+
+	var le LengthError
+	require.True(t, stderrors.As(err, &le))
+	assert.Equal(t, int64(12), le.Offset)
+
+	// Truncated input reports the offset of the field which could not be read
+	err = Unmarshal(buf[0:10], &o)
+	require.True(t, stderrors.As(err, &fe))
+	assert.Equal(t, int64(8), fe.Offset)
+
+	// Offsets are not known when encoding
+	o.Inner.B = make([]byte, 5)
+	_, err = Marshal(&o)
+	require.True(t, stderrors.As(err, &fe))
+	assert.Equal(t, int64(-1), fe.Offset)
+	assert.NotContains(t, err.Error(), ", offset")
+
+	d := NewDecoder(bytes.NewReader(buf))
+	var x uint64
+	require.NoError(t, d.Decode(&x))
+	assert.Equal(t, int64(8), d.Offset())
+	_, r, err := d.OpaqueReader(16)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, int64(16), d.Offset())
+}
+
+type pathEntry struct {
+	Name string `xdr:"maxlen:4"`
+}
+
+type pathUnion struct {
+	Kind  int32       `xdr:"union:switch"`
+	Entry pathEntry   `xdr:"union:1"`
+	List  []pathEntry `xdr:"union:2"`
+}
+
+type pathRoot struct {
+	Entries []pathEntry
+	Map     map[string]pathEntry
+	Union   pathUnion
+}
+
+func TestErrorPaths(t *testing.T) {
+	long := pathEntry{Name: "toolong"}
+	entries := make([]pathEntry, 18)
+	entries[17] = long
+
+	cases := []struct {
+		name string
+		v    pathRoot
+		path []PathElem
+		str  string
+	}{
+		{
+			name: "Slice",
+			v:    pathRoot{Entries: entries},
+			path: []PathElem{
+				{Kind: PathField, Type: "pathRoot", Name: "Entries"},
+				{Kind: PathIndex, Index: 17},
+				{Kind: PathField, Type: "pathEntry", Name: "Name"},
+			},
+			str: "pathRoot.Entries[17].Name",
+		},
+		{
+			name: "Map",
+			v:    pathRoot{Map: map[string]pathEntry{"k": long}},
+			path: []PathElem{
+				{Kind: PathField, Type: "pathRoot", Name: "Map"},
+				{Kind: PathMapKey, Key: "k"},
+				{Kind: PathField, Type: "pathEntry", Name: "Name"},
+			},
+			str: `pathRoot.Map["k"].Name`,
+		},
+		{
+			name: "Union",
+			v:    pathRoot{Union: pathUnion{Kind: 2, List: []pathEntry{{}, long}}},
+			path: []PathElem{
+				{Kind: PathField, Type: "pathRoot", Name: "Union"},
+				{Kind: PathUnionArm, Type: "pathUnion", Name: "List", Case: int32(2)},
+				{Kind: PathIndex, Index: 1},
+				{Kind: PathField, Type: "pathEntry", Name: "Name"},
+			},
+			str: "pathRoot.Union.List[1].Name",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fe FieldError
+			_, err := Marshal(&tc.v)
+			require.True(t, stderrors.As(err, &fe), "Expected FieldError, got %v", err)
+			assert.True(t, stderrors.Is(err, ErrLengthExceedsMax))
+			assert.Equal(t, tc.path, fe.Path)
+			assert.Equal(t, tc.str, fe.PathString())
+			assert.Equal(t, int64(-1), fe.Offset)
+		})
+	}
+
+	// Decode failures carry the offset of the failing element
+	buf := []byte{
+		0, 0, 0, 2, // Entries length
+		0, 0, 0, 0, // Entries[0].Name
+		0, 0, 0, 5, // Entries[1].Name length
+	}
+	var r pathRoot
+	err := Unmarshal(buf, &r)
+	var fe FieldError
+	require.True(t, stderrors.As(err, &fe), "Expected FieldError, got %v", err)
+	assert.Equal(t, "pathRoot.Entries[1].Name", fe.PathString())
+	assert.Equal(t, int64(8), fe.Offset)
+}
+
+func TestArrayIter(t *testing.T) {
+	in := []pathEntry{{Name: "a"}, {Name: "bb"}, {Name: "ccc"}}
+	buf, err := Marshal(&in)
+	require.NoError(t, err)
+
+	d := NewDecoder(bytes.NewReader(buf))
+	n, next, err := d.ArrayIter(8)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), n)
+
+	var out []pathEntry
+	for {
+		var e pathEntry
+		err := next(&e)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		out = append(out, e)
+	}
+	assert.Equal(t, in, out)
+	assert.Equal(t, int64(len(buf)), d.Offset())
+
+	// Too long
+	d = NewDecoder(bytes.NewReader(buf))
+	_, _, err = d.ArrayIter(2)
+	assert.True(t, stderrors.Is(err, ErrLengthExceedsMax))
+
+	// Truncated
+	d = NewDecoder(bytes.NewReader(buf[0:12]))
+	_, next, err = d.ArrayIter(8)
+	require.NoError(t, err)
+	var e pathEntry
+	require.NoError(t, next(&e))
+	err = next(&e)
+	var fe FieldError
+	require.True(t, stderrors.As(err, &fe), "Expected FieldError, got %v", err)
+	assert.Equal(t, "[1].Name", fe.PathString())
+	assert.Equal(t, io.ErrUnexpectedEOF, fe.Underlying)
+
+	// Once failed, the iterator continues to fail rather than retrying
+	assert.Equal(t, err, next(&e))
+	assert.Equal(t, err, next(&e))
+
+	// Limits apply to the array
+	d = NewDecoder(bytes.NewReader(buf))
+	d.SetLimits(Limits{MaxElements: 2})
+	_, _, err = d.ArrayIter(8)
+	assert.True(t, stderrors.Is(err, ErrLimitExceeded), "Expected ErrLimitExceeded, got %v", err)
+
+	d = NewDecoder(bytes.NewReader(buf))
+	d.SetLimits(Limits{MaxDepth: 1})
+	_, next, err = d.ArrayIter(8)
+	require.NoError(t, err)
+	err = next(&e)
+	assert.True(t, stderrors.Is(err, ErrLimitExceeded), "Expected ErrLimitExceeded, got %v", err)
+
+	d = NewDecoder(bytes.NewReader(buf))
+	d.SetLimits(Limits{MaxDepth: 2})
+	_, next, err = d.ArrayIter(8)
+	require.NoError(t, err)
+	for err = next(&e); err == nil; err = next(&e) {
+	}
+	assert.Equal(t, io.EOF, err)
+
+	// Once iteration has finished, the array no longer counts towards the depth
+	trailer, err := Marshal(&pathEntry{Name: "d"})
+	require.NoError(t, err)
+	d = NewDecoder(bytes.NewReader(append([]byte{0, 0, 0, 0}, trailer...)))
+	d.SetLimits(Limits{MaxDepth: 1})
+	_, next, err = d.ArrayIter(8)
+	require.NoError(t, err)
+	assert.Equal(t, io.EOF, next(&e))
+	require.NoError(t, d.Decode(&e))
+	assert.Equal(t, "d", e.Name)
+}
+
+func TestOpaqueWriter(t *testing.T) {
+	body := []byte("hello")
+	expect := []byte{0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o', 0, 0, 0}
+
+	t.Run("Write", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		w, err := e.OpaqueWriter(5)
+		require.NoError(t, err)
+		_, err = w.Write(body[0:2])
+		require.NoError(t, err)
+		_, err = w.Write(body[2:])
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Equal(t, expect, buf.Bytes())
+	})
+
+	t.Run("ReadFrom", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		w := e.FixedOpaqueWriter(5)
+		_, err := io.Copy(w, bytes.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Equal(t, expect[4:], buf.Bytes())
+
+		w = e.FixedOpaqueWriter(4)
+		r := bytes.NewReader(body)
+		_, err = io.Copy(w, r)
+		assert.Equal(t, ErrLengthIncorrect, err)
+		// The byte used to probe for excess data is returned to the reader
+		assert.Equal(t, 1, r.Len())
+	})
+
+	t.Run("Short", func(t *testing.T) {
+		e := NewEncoder(ioutil.Discard)
+		w, err := e.OpaqueWriter(6)
+		require.NoError(t, err)
+		_, err = w.Write(body)
+		require.NoError(t, err)
+		assert.Equal(t, ErrLengthIncorrect, w.Close())
+	})
+
+	t.Run("Long", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		w, err := e.OpaqueWriter(4)
+		require.NoError(t, err)
+		n, err := w.Write(body)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, ErrLengthIncorrect, err)
+	})
+
+	t.Run("EncodeOpaqueFrom", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		require.NoError(t, e.EncodeOpaqueFrom(bytes.NewReader(body), 5))
+		assert.Equal(t, expect, buf.Bytes())
+
+		err := e.EncodeOpaqueFrom(bytes.NewReader(body), 6)
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+}
+
+type sortedKey struct {
+	A uint16
+	B string
+}
+
+func TestSortedMaps(t *testing.T) {
+	c := NewCoder()
+	c.SetSortedMaps(true)
+
+	t.Run("Int", func(t *testing.T) {
+		m := map[int32]bool{-1: true, 0: false, 2: true, -3: false}
+		buf, err := c.Marshal(&m)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{
+			0, 0, 0, 4,
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 2, 0, 0, 0, 1,
+			0xff, 0xff, 0xff, 0xfd, 0, 0, 0, 0,
+			0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1,
+		}, buf)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		m := map[string]uint8{"b": 1, "aa": 2, "a": 3}
+		buf, err := c.Marshal(&m)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{
+			0, 0, 0, 3,
+			0, 0, 0, 1, 'a', 0, 0, 0, 0, 0, 0, 3,
+			0, 0, 0, 1, 'b', 0, 0, 0, 0, 0, 0, 1,
+			0, 0, 0, 2, 'a', 'a', 0, 0, 0, 0, 0, 2,
+		}, buf)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		m := map[sortedKey]uint32{}
+		for i := 0; i < 64; i++ {
+			m[sortedKey{uint16(i % 4), string(rune('a' + i))}] = uint32(i)
+		}
+
+		buf, err := c.Marshal(&m)
+		require.NoError(t, err)
+		for i := 0; i < 8; i++ {
+			again, err := c.Marshal(&m)
+			require.NoError(t, err)
+			assert.Equal(t, buf, again)
+		}
+
+		// Keys are (A, B) in order; each entry is 16 bytes
+		require.Equal(t, 4+64*16, len(buf))
+		for i := 1; i < 64; i++ {
+			prev, cur := buf[4+(i-1)*16:4+(i-1)*16+12], buf[4+i*16:4+i*16+12]
+			assert.True(t, bytes.Compare(prev, cur) < 0)
+		}
+
+		var out map[sortedKey]uint32
+		require.NoError(t, c.Unmarshal(buf, &out))
+		assert.Equal(t, m, out)
+	})
+
+	t.Run("Encoder", func(t *testing.T) {
+		m := map[uint32]uint32{3: 0, 1: 0, 2: 0}
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetSortedMaps(true)
+		require.NoError(t, e.Encode(&m))
+		assert.Equal(t, []byte{
+			0, 0, 0, 3,
+			0, 0, 0, 1, 0, 0, 0, 0,
+			0, 0, 0, 2, 0, 0, 0, 0,
+			0, 0, 0, 3, 0, 0, 0, 0,
+		}, buf.Bytes())
+	})
+
+	assert.Panics(t, func() { DefaultCoder.SetSortedMaps(true) })
+}
+
+func TestGoInts(t *testing.T) {
+	type ints struct {
+		I   int
+		U   uint
+		P   uintptr
+		I32 int  `xdr:"int"`
+		U32 uint `xdr:"int"`
+		I64 int  `xdr:"hyper"`
+	}
+
+	is64Bit := func(t *testing.T, dir testDirection) (bool, string) {
+		return strconv.IntSize != 64, "Requires 64-bit platform"
+	}
+
+	// Out of range values (on 64-bit platforms). Computed at run time, so that
+	// this compiles on 32-bit platforms
+	bigInt, bigUint := int64(math.MaxInt32), uint64(math.MaxUint32)
+	bigInt++
+	bigUint++
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "int",
+			Object: int(-2),
+			Bytes:  []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		}, {
+			Name:   "uint",
+			Object: uint(3),
+			Bytes:  []byte{0, 0, 0, 0, 0, 0, 0, 3},
+		}, {
+			Name:   "uintptr",
+			Object: uintptr(4),
+			Bytes:  []byte{0, 0, 0, 0, 0, 0, 0, 4},
+		}, {
+			Name:   "Tagged struct",
+			Object: ints{-1, 2, 3, -4, 5, -6},
+			Bytes: []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0, 0, 0, 0, 0, 0, 0, 2,
+				0, 0, 0, 0, 0, 0, 0, 3,
+				0xff, 0xff, 0xff, 0xfc,
+				0, 0, 0, 5,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfa,
+			},
+		}, {
+			Name:       "int overflow",
+			Direction:  encodeTest,
+			ShouldSkip: is64Bit,
+			Object:     ints{I32: int(bigInt)},
+			EncErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:       "uint overflow",
+			Direction:  encodeTest,
+			ShouldSkip: is64Bit,
+			Object:     ints{U32: uint(bigUint)},
+			EncErrorIs: ErrIntegerOverflow,
+		},
+	})
+}
+
+func TestNarrowIntOverflow(t *testing.T) {
+	type wrapped struct {
+		I8  int8   `xdr:"wrap"`
+		U16 uint16 `xdr:"wrap"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "int8 in range",
+			Object: int8(-128),
+			Bytes:  []byte{0xff, 0xff, 0xff, 0x80},
+		}, {
+			Name:       "int8 overflow",
+			Direction:  decodeTest,
+			Object:     int8(0),
+			Bytes:      []byte{0xff, 0xff, 0xff, 0x7f},
+			DecErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:       "int16 overflow",
+			Direction:  decodeTest,
+			Object:     int16(0),
+			Bytes:      []byte{0, 0, 0x80, 0},
+			DecErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:       "uint8 overflow",
+			Direction:  decodeTest,
+			Object:     uint8(0),
+			Bytes:      []byte{0, 0, 1, 0},
+			DecErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:       "uint16 overflow",
+			Direction:  decodeTest,
+			Object:     uint16(0),
+			Bytes:      []byte{0, 1, 0x11, 0x70},
+			DecErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:       "struct field overflow",
+			Direction:  decodeTest,
+			Object:     struct{ U8 uint8 }{},
+			Bytes:      []byte{0, 0, 1, 0},
+			DecErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:      "wrap",
+			Direction: decodeTest,
+			Object:    wrapped{I8: 0x7f, U16: 0x1170},
+			Bytes:     []byte{0xff, 0xff, 0xff, 0x7f, 0, 1, 0x11, 0x70},
+		},
+	})
+}
+
+func TestUnionSwitchKinds(t *testing.T) {
+	type int8Union struct {
+		S   int8   `xdr:"union:switch"`
+		Err uint32 `xdr:"union:-1"`
+		Ok  string `xdr:"union:0,1"`
+	}
+
+	type uint16Union struct {
+		S uint16 `xdr:"union:switch"`
+		A uint32 `xdr:"union:0xffff"`
+	}
+
+	type int64Union struct {
+		S int64  `xdr:"union:switch"`
+		A uint32 `xdr:"union:-2"`
+		B uint32 `xdr:"union:default"`
+	}
+
+	type intUnion struct {
+		S int    `xdr:"union:switch"`
+		A uint32 `xdr:"union:2147483647"`
+	}
+
+	type uint64Union struct {
+		S uint64 `xdr:"union:switch"`
+		A uint32 `xdr:"union:2147483648"`
+		B uint32 `xdr:"union:4294967295"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "int8 negative case",
+			Object: int8Union{S: -1, Err: 5},
+			Bytes:  []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 5},
+		}, {
+			Name:   "int8 positive case",
+			Object: int8Union{S: 1, Ok: "a"},
+			Bytes:  []byte{0, 0, 0, 1, 0, 0, 0, 1, 'a', 0, 0, 0},
+		}, {
+			Name:       "int8 undefined arm",
+			Object:     int8Union{S: 2},
+			Bytes:      []byte{0, 0, 0, 2},
+			EncErrorIs: ErrUnionSwitchArmUndefined,
+			DecErrorIs: ErrUnionSwitchArmUndefined,
+		}, {
+			Name:   "uint16",
+			Object: uint16Union{S: 0xffff, A: 3},
+			Bytes:  []byte{0, 0, 0xff, 0xff, 0, 0, 0, 3},
+		}, {
+			Name:   "int64 negative case",
+			Object: int64Union{S: -2, A: 4},
+			Bytes:  []byte{0xff, 0xff, 0xff, 0xfe, 0, 0, 0, 4},
+		}, {
+			Name:   "int64 default",
+			Object: int64Union{S: 3, B: 4},
+			Bytes:  []byte{0, 0, 0, 3, 0, 0, 0, 4},
+		}, {
+			Name:       "int64 switch overflow",
+			Direction:  encodeTest,
+			Object:     int64Union{S: 1 << 31, B: 4},
+			EncErrorIs: ErrIntegerOverflow,
+		}, {
+			Name:   "int wide case",
+			Object: intUnion{S: math.MaxInt32, A: 1},
+			Bytes:  []byte{0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 1},
+		}, {
+			Name:   "uint64 case >= 2^31",
+			Object: uint64Union{S: 1 << 31, A: 1},
+			Bytes:  []byte{0x80, 0, 0, 0, 0, 0, 0, 1},
+		}, {
+			Name:   "uint64 maximum case",
+			Object: uint64Union{S: math.MaxUint32, B: 2},
+			Bytes:  []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 2},
+		}, {
+			Name:       "uint64 switch overflow",
+			Direction:  encodeTest,
+			Object:     uint64Union{S: 1 << 32},
+			EncErrorIs: ErrIntegerOverflow,
+		},
+	})
+
+	t.Run("Case out of range", func(t *testing.T) {
+		type uint8Union struct {
+			S uint8  `xdr:"union:switch"`
+			A uint32 `xdr:"union:256"`
+		}
+
+		type int64Union struct {
+			S int64  `xdr:"union:switch"`
+			A uint32 `xdr:"union:2147483648"`
+		}
+
+		type intUnion struct {
+			S int    `xdr:"union:switch"`
+			A uint32 `xdr:"union:4294967294"`
+		}
+
+		_, err := Marshal(uint8Union{})
+		assert.Error(t, err)
+		_, err = Marshal(int64Union{})
+		assert.Error(t, err)
+		_, err = Marshal(intUnion{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Negative case on unsigned switch", func(t *testing.T) {
+		type uint8Union struct {
+			S uint8  `xdr:"union:switch"`
+			A uint32 `xdr:"union:-1"`
+		}
+
+		type uint32Union struct {
+			S uint32 `xdr:"union:switch"`
+			A uint32 `xdr:"union:-1"`
+		}
+
+		type uint64Union struct {
+			S uint64 `xdr:"union:switch"`
+			A uint32 `xdr:"union:-1"`
+		}
+
+		_, err := Marshal(uint8Union{})
+		assert.Error(t, err)
+		_, err = Marshal(uint32Union{})
+		assert.Error(t, err)
+		_, err = Marshal(uint64Union{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Error path case value", func(t *testing.T) {
+		var u int8Union
+		err := Unmarshal([]byte{0xff, 0xff, 0xff, 0xff}, &u)
+		var fe FieldError
+		if assert.True(t, stderrors.As(err, &fe)) {
+			assert.Equal(t, int8(-1), fe.Path[0].Case)
+		}
+	})
+}
+
+type constUnion struct {
+	Status int32    `xdr:"union:switch"`
+	Ok     uint32   `xdr:"union:NFS3_OK"`
+	Err    struct{} `xdr:"union:NFS3ERR_NOENT,NFS3ERR_IO"`
+}
+
+func TestConstants(t *testing.T) {
+	c := NewCoder()
+	c.RegisterConstants(map[string]int64{
+		"NFS3_OK":       0,
+		"NFS3ERR_NOENT": 2,
+		"NFS3ERR_IO":    5,
+	})
+
+	buf, err := c.Marshal(constUnion{Status: 0, Ok: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 7}, buf)
+
+	var u constUnion
+	assert.NoError(t, c.Unmarshal([]byte{0, 0, 0, 5}, &u))
+	assert.Equal(t, int32(5), u.Status)
+
+	// Re-registering with the same value is permitted; a different value is not
+	c.RegisterConstants(map[string]int64{"NFS3_OK": 0})
+	assert.Panics(t, func() {
+		c.RegisterConstants(map[string]int64{"NFS3_OK": 1})
+	})
+
+	// Out of range for an int32 switch (rather than wrapping to -1)
+	type wideConstUnion struct {
+		Status int32  `xdr:"union:switch"`
+		All    uint32 `xdr:"union:ALL_ONES"`
+	}
+	c.RegisterConstants(map[string]int64{"ALL_ONES": 0xFFFFFFFF})
+	_, err = c.Marshal(wideConstUnion{})
+	assert.Error(t, err)
+
+	// Undefined on the default coder
+	_, err = Marshal(constUnion{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Undefined constant 'NFS3_OK'")
+	}
+}
+
+type testEnum int32
+
+func TestEnums(t *testing.T) {
+	c := NewCoder()
+	c.RegisterEnum(testEnum(0), map[string]int32{
+		"ENUM_A":     1,
+		"ENUM_B":     -2,
+		"ENUM_ALIAS": 1,
+	})
+
+	buf, err := c.Marshal(testEnum(-2))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xfe}, buf)
+
+	var e testEnum
+	assert.NoError(t, c.Unmarshal([]byte{0, 0, 0, 1}, &e))
+	assert.Equal(t, testEnum(1), e)
+
+	err = c.Unmarshal([]byte{0, 0, 0, 3}, &e)
+	assert.True(t, stderrors.Is(err, ErrInvalidEnumValue), "Expected ErrInvalidEnumValue, got %v", err)
+
+	// Inside a struct, the error is reported with the field path
+	var s struct{ E testEnum }
+	err = c.Unmarshal([]byte{0, 0, 0, 3}, &s)
+	assert.True(t, stderrors.Is(err, ErrInvalidEnumValue), "Expected ErrInvalidEnumValue, got %v", err)
+
+	// Errors name the declared values...
+	var ev EnumValueError
+	if assert.True(t, stderrors.As(err, &ev)) {
+		assert.Equal(t, []string{"ENUM_B", "ENUM_A", "ENUM_ALIAS"}, ev.Declared)
+		assert.Equal(t, "xdr: Invalid enum value (3 is not a declared value of xdr.testEnum; "+
+			"expected one of ENUM_B, ENUM_A, ENUM_ALIAS)", ev.Error())
+	}
+
+	// ...and the case of unions switched by an enum
+	type enumUnion struct {
+		S testEnum `xdr:"union:switch"`
+		A string   `xdr:"union:1/maxlen:1"`
+	}
+
+	_, err = c.Marshal(enumUnion{S: 1, A: "ab"})
+	var fe FieldError
+	if assert.True(t, stderrors.As(err, &fe)) {
+		assert.Equal(t, "ENUM_A", fe.Path[0].CaseName)
+		assert.Equal(t, "enumUnion.A<ENUM_A>", fe.PathString())
+	}
+
+	// Unknown values may be permitted per decoder...
+	d := c.NewDecoder(bytes.NewReader([]byte{0, 0, 0, 3}))
+	d.SetAllowUnknownEnums(true)
+	assert.NoError(t, d.Decode(&e))
+	assert.Equal(t, testEnum(3), e)
+
+	// ...and are always permitted when encoding
+	buf, err = c.Marshal(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 3}, buf)
+
+	// ...or per coder
+	c.SetAllowUnknownEnums(true)
+	assert.NoError(t, c.Unmarshal([]byte{0, 0, 0, 4}, &e))
+	assert.Equal(t, testEnum(4), e)
+
+	// Names
+	assert.Equal(t, "ENUM_A", c.EnumString(testEnum(1)))
+	assert.Equal(t, "ENUM_B", c.EnumString(testEnum(-2)))
+	assert.Equal(t, "xdr.testEnum(7)", c.EnumString(testEnum(7)))
+	_, ok := c.EnumName(int32(1))
+	assert.False(t, ok)
+
+	// Unregistered on the default coder, so no validation is performed
+	assert.NoError(t, Unmarshal([]byte{0, 0, 0, 3}, &e))
+
+	assert.Panics(t, func() {
+		c.RegisterEnum(testEnum(0), map[string]int32{"ENUM_A": 1})
+	})
+	assert.Panics(t, func() {
+		type smallEnum uint8
+		NewCoder().RegisterEnum(smallEnum(0), map[string]int32{"NEG": -1})
+	})
+
+	// Integer encoding tags cannot be used to bypass validation
+	type intEnum int
+	type narrowEnum int16
+	c = NewCoder()
+	c.RegisterEnum(intEnum(0), map[string]int32{"A": 1})
+	c.RegisterEnum(narrowEnum(0), map[string]int32{"A": 1})
+
+	var ti struct {
+		X intEnum `xdr:"int"`
+	}
+	err = c.Unmarshal([]byte{0, 0, 0, 5}, &ti)
+	assert.True(t, stderrors.As(err, &InvalidTagForTypeError{}), "Expected InvalidTagForTypeError, got %v", err)
+
+	var th struct {
+		X intEnum `xdr:"hyper"`
+	}
+	err = c.Unmarshal([]byte{0, 0, 0, 0, 0, 0, 0, 5}, &th)
+	assert.True(t, stderrors.As(err, &InvalidTagForTypeError{}), "Expected InvalidTagForTypeError, got %v", err)
+
+	var tw struct {
+		X narrowEnum `xdr:"wrap"`
+	}
+	err = c.Unmarshal([]byte{0, 0, 0, 5}, &tw)
+	assert.True(t, stderrors.As(err, &InvalidTagForTypeError{}), "Expected InvalidTagForTypeError, got %v", err)
+
+	// ...but untagged, they decode as enums
+	var tu struct{ X intEnum }
+	err = c.Unmarshal([]byte{0, 0, 0, 5}, &tu)
+	assert.True(t, stderrors.Is(err, ErrInvalidEnumValue), "Expected ErrInvalidEnumValue, got %v", err)
+	assert.NoError(t, c.Unmarshal([]byte{0, 0, 0, 1}, &tu))
+	assert.Equal(t, intEnum(1), tu.X)
+}
+
+type EmbedHeader struct {
+	Xid  uint32
+	Kind uint32
+}
+
+type EmbedTrailer struct {
+	Check uint32
+}
+
+type embedHidden struct {
+	H uint32
+}
+
+type embedMsg struct {
+	EmbedHeader
+	Body string `xdr:"maxlen:4"`
+	*EmbedTrailer
+}
+
+type embedTagged struct {
+	*EmbedHeader `xdr:"opt"`
+	Body         uint32
+}
+
+type embedUnexported struct {
+	*embedHidden
+	Body uint32
+}
+
+func TestEmbedded(t *testing.T) {
+	RunTestcases(t, []testcase{
+		{
+			Name: "Promoted fields",
+			Object: embedMsg{
+				EmbedHeader:  EmbedHeader{Xid: 1, Kind: 2},
+				Body:         "ab",
+				EmbedTrailer: &EmbedTrailer{Check: 3},
+			},
+			Bytes: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 2,
+				0, 0, 0, 2, 'a', 'b', 0, 0,
+				0, 0, 0, 3,
+			},
+		}, {
+			Name:       "Nil embedded pointer",
+			Direction:  encodeTest,
+			Object:     embedMsg{Body: "ab"},
+			EncErrorIs: ErrNilPointer,
+		}, {
+			Name:   "Tagged embedded pointer",
+			Object: embedTagged{Body: 7},
+			Bytes:  []byte{0, 0, 0, 0, 0, 0, 0, 7},
+		}, {
+			Name:   "Unexported embedded pointer",
+			Object: embedUnexported{embedHidden: &embedHidden{H: 1}, Body: 2},
+			Bytes:  []byte{0, 0, 0, 1, 0, 0, 0, 2},
+			// Decoding requires allocating the unexported type
+			Direction: encodeTest,
+		},
+	})
+
+	t.Run("Error path", func(t *testing.T) {
+		var m embedMsg
+		err := Unmarshal([]byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 5}, &m)
+		var fe FieldError
+		if assert.True(t, stderrors.As(err, &fe), "Expected FieldError, got %v", err) {
+			assert.Equal(t, "embedMsg.Body", fe.PathString())
+		}
+	})
+
+	t.Run("Unexported allocation", func(t *testing.T) {
+		var m embedUnexported
+		err := Unmarshal([]byte{0, 0, 0, 1, 0, 0, 0, 2}, &m)
+		assert.Error(t, err)
+
+		// Already allocated pointers are fine
+		m.embedHidden = &embedHidden{}
+		assert.NoError(t, Unmarshal([]byte{0, 0, 0, 1, 0, 0, 0, 2}, &m))
+		assert.Equal(t, uint32(1), m.H)
+	})
+}
+
+func TestOptList(t *testing.T) {
+	type export struct {
+		Dir    string   `xdr:"maxlen:8"`
+		Groups []string `xdr:"optlist/maxlen:8"`
+	}
+
+	type exports struct {
+		List []export `xdr:"optlist:2"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Empty",
+			Object: exports{},
+			Bytes:  []byte{0, 0, 0, 0},
+		}, {
+			Name: "Nested",
+			Object: exports{List: []export{
+				{Dir: "/a", Groups: []string{"x", "yz"}},
+				{Dir: "/b"},
+			}},
+			Bytes: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 2, '/', 'a', 0, 0,
+				0, 0, 0, 1, 0, 0, 0, 1, 'x', 0, 0, 0,
+				0, 0, 0, 1, 0, 0, 0, 2, 'y', 'z', 0, 0,
+				0, 0, 0, 0,
+				0, 0, 0, 1,
+				0, 0, 0, 2, '/', 'b', 0, 0,
+				0, 0, 0, 0,
+				0, 0, 0, 0,
+			},
+		}, {
+			Name:       "Too long",
+			Object:     exports{List: make([]export, 3)},
+			Bytes:      []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		}, {
+			Name:       "Truncated",
+			Direction:  decodeTest,
+			Object:     exports{},
+			Bytes:      []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+			DecErrorIs: io.ErrUnexpectedEOF,
+		},
+	})
+}
+
+func TestQuadruple(t *testing.T) {
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Quadruple 1",
+			Object: QuadrupleFromFloat64(1),
+			Bytes:  []byte{0x3f, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		}, {
+			Name:   "Quadruple -2.5",
+			Object: QuadrupleFromFloat64(-2.5),
+			Bytes:  []byte{0xc0, 0x00, 0x40, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+	})
+
+	_, err := Marshal(struct {
+		Q Quadruple `xdr:"int"`
+	}{})
+	assert.Error(t, err)
+
+	pow2 := func(e int) *big.Float {
+		return new(big.Float).SetMantExp(big.NewFloat(1), e)
+	}
+	sum := func(a, b *big.Float) *big.Float {
+		return new(big.Float).SetPrec(200).Add(a, b)
+	}
+
+	t.Run("Float64", func(t *testing.T) {
+		for _, f := range []float64{
+			0, 1, -1, 0.1, math.Pi, math.MaxFloat64, -math.SmallestNonzeroFloat64,
+			math.Inf(1), math.Inf(-1),
+		} {
+			q := QuadrupleFromFloat64(f)
+			assert.Equal(t, f, q.Float64(), "Round trip of %v", f)
+		}
+
+		negZero := QuadrupleFromFloat64(math.Copysign(0, -1))
+		assert.Equal(t, Quadruple{Hi: 1 << 63}, negZero)
+		assert.True(t, math.Signbit(negZero.Float64()))
+
+		nan := QuadrupleFromFloat64(math.NaN())
+		assert.True(t, nan.IsNaN())
+		assert.True(t, math.IsNaN(nan.Float64()))
+		assert.True(t, math.IsNaN(QuadrupleNaN().Float64()))
+		assert.Nil(t, nan.BigFloat())
+
+		// A NaN whose payload is entirely in the bits discarded by Float64
+		assert.True(t, math.IsNaN(Quadruple{Hi: 0x7fff000000000000, Lo: 1}.Float64()))
+
+		// Rounding: 1 + 2^-53 is a tie, which rounds to even (1); 1 + 3*2^-54 is not
+		assert.Equal(t, 1.0, QuadrupleFromBigFloat(sum(pow2(0), pow2(-53))).Float64())
+		assert.Equal(t, 1+math.Pow(2, -52),
+			QuadrupleFromBigFloat(sum(pow2(0), sum(pow2(-53), pow2(-54)))).Float64())
+
+		// Out of range for float64
+		assert.Equal(t, math.Inf(-1), QuadrupleFromBigFloat(new(big.Float).Neg(pow2(2000))).Float64())
+		assert.Equal(t, 0.0, QuadrupleFromBigFloat(pow2(-2000)).Float64())
+		assert.Equal(t, math.SmallestNonzeroFloat64, QuadrupleFromBigFloat(pow2(-1074)).Float64())
+	})
+
+	t.Run("BigFloat", func(t *testing.T) {
+		// Smallest subnormal, and values around half of it
+		assert.Equal(t, Quadruple{Lo: 1}, QuadrupleFromBigFloat(pow2(-16494)))
+		assert.Equal(t, Quadruple{}, QuadrupleFromBigFloat(pow2(-16495)))
+		assert.Equal(t, Quadruple{Lo: 1}, QuadrupleFromBigFloat(sum(pow2(-16495), pow2(-16600))))
+		assert.Equal(t, Quadruple{Hi: 1 << 63}, QuadrupleFromBigFloat(new(big.Float).Neg(pow2(-17000))))
+
+		// Subnormal rounding: 2^-16494 * 2.5 is a tie, which rounds to even
+		assert.Equal(t, Quadruple{Lo: 2}, QuadrupleFromBigFloat(sum(pow2(-16493), pow2(-16495))))
+
+		// Largest subnormal rounds up to smallest normal
+		largestSub := Quadruple{Hi: 0x0000ffffffffffff, Lo: math.MaxUint64}
+		assert.Equal(t, Quadruple{Hi: 0x0001000000000000},
+			QuadrupleFromBigFloat(sum(largestSub.BigFloat(), pow2(-16495))))
+		assert.Equal(t, largestSub, QuadrupleFromBigFloat(largestSub.BigFloat()))
+
+		// Largest finite value, and rounding up to infinity
+		max := Quadruple{Hi: 0x7ffeffffffffffff, Lo: math.MaxUint64}
+		assert.Equal(t, max, QuadrupleFromBigFloat(max.BigFloat()))
+		assert.Equal(t, QuadrupleInf(1), QuadrupleFromBigFloat(sum(max.BigFloat(), pow2(16383-113))))
+		assert.Equal(t, QuadrupleInf(-1), QuadrupleFromBigFloat(new(big.Float).SetInf(true)))
+		assert.True(t, QuadrupleInf(-1).IsInf(-1))
+		assert.True(t, QuadrupleInf(-1).BigFloat().IsInf())
+
+		// 113 bits of precision are preserved
+		v := sum(pow2(0), pow2(-112))
+		q := QuadrupleFromBigFloat(v)
+		assert.Equal(t, Quadruple{Hi: 0x3fff000000000000, Lo: 1}, q)
+		assert.Equal(t, 0, v.Cmp(q.BigFloat()))
+	})
+}
+
+type testShape interface {
+	Sides() int
+}
+
+type testCircle struct {
+	R uint32
+}
+
+type testPolygon struct {
+	Points []uint32 `xdr:"maxlen:4"`
+}
+
+func (testCircle) Sides() int   { return 0 }
+func (*testPolygon) Sides() int { return 3 }
+
+type testDrawing struct {
+	Main  testShape
+	Extra testShape `xdr:"opt"`
+}
+
+func TestInterfaces(t *testing.T) {
+	c := NewCoder()
+	c.RegisterInterface((*testShape)(nil), map[int32]interface{}{
+		1:  testCircle{},
+		-2: &testPolygon{},
+	})
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Value arm",
+			Coder:  c,
+			Object: testDrawing{Main: testCircle{R: 5}},
+			Bytes:  []byte{0, 0, 0, 1, 0, 0, 0, 5, 0, 0, 0, 0},
+		}, {
+			Name:  "Pointer arm, opt",
+			Coder: c,
+			Object: testDrawing{
+				Main:  &testPolygon{Points: []uint32{7}},
+				Extra: testCircle{R: 1},
+			},
+			Bytes: []byte{
+				0xff, 0xff, 0xff, 0xfe, 0, 0, 0, 1, 0, 0, 0, 7,
+				0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1,
+			},
+		}, {
+			Name:       "Nil",
+			Coder:      c,
+			Direction:  encodeTest,
+			Object:     testDrawing{},
+			EncErrorIs: ErrNilPointer,
+		}, {
+			Name:       "Unknown discriminant",
+			Coder:      c,
+			Direction:  decodeTest,
+			Object:     testDrawing{},
+			Bytes:      []byte{0, 0, 0, 3, 0, 0, 0, 0},
+			DecErrorIs: ErrUnionSwitchArmUndefined,
+		}, {
+			Name:       "Arm error",
+			Coder:      c,
+			Direction:  decodeTest,
+			Object:     testDrawing{},
+			Bytes:      []byte{0xff, 0xff, 0xff, 0xfe, 0, 0, 0, 5},
+			DecErrorIs: ErrLengthExceedsMax,
+		},
+	})
+
+	assert.Panics(t, func() {
+		NewCoder().RegisterInterface((*testShape)(nil), map[int32]interface{}{1: testPolygon{}})
+	})
+}
+
+func TestUnionInfer(t *testing.T) {
+	type result struct {
+		Status int32    `xdr:"union:switch,infer"`
+		Ok     *uint32  `xdr:"union:0"`
+		Err    *string  `xdr:"union:2,5"`
+		Void   struct{} `xdr:"union:7"`
+	}
+
+	five := uint32(5)
+	msg := "no"
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Consistent",
+			Object: result{Status: 0, Ok: &five},
+			Bytes:  []byte{0, 0, 0, 0, 0, 0, 0, 5},
+		}, {
+			Name:      "Inferred",
+			Direction: encodeTest,
+			Object:    result{Err: &msg},
+			Bytes:     []byte{0, 0, 0, 2, 0, 0, 0, 2, 'n', 'o', 0, 0},
+		}, {
+			Name:   "Explicit second case",
+			Object: result{Status: 5, Err: &msg},
+			Bytes:  []byte{0, 0, 0, 5, 0, 0, 0, 2, 'n', 'o', 0, 0},
+		}, {
+			Name:   "Nothing populated",
+			Object: result{Status: 7},
+			Bytes:  []byte{0, 0, 0, 7},
+		}, {
+			Name:       "Conflict",
+			Direction:  encodeTest,
+			Object:     result{Status: 5, Ok: &five},
+			EncErrorIs: ErrUnionArmMismatch,
+		}, {
+			Name:       "Conflict with void arm",
+			Direction:  encodeTest,
+			Object:     result{Status: 7, Err: &msg},
+			EncErrorIs: ErrUnionArmMismatch,
+		}, {
+			Name:       "Multiple arms",
+			Direction:  encodeTest,
+			Object:     result{Ok: &five, Err: &msg},
+			EncErrorIs: ErrUnionArmMismatch,
+		}, {
+			Name:       "Selected arm nil",
+			Direction:  encodeTest,
+			Object:     result{Status: 2},
+			EncErrorIs: ErrNilPointer,
+		},
+	})
+}
+
+type testFileHandle []byte
+
+func (testFileHandle) XDRTag() string { return "maxlen:4/opaque" }
+
+type testBadTagged int32
+
+func (testBadTagged) XDRTag() string { return "opaque" }
+
+func TestTypeTags(t *testing.T) {
+	type handles struct {
+		A testFileHandle
+		B testFileHandle  `xdr:"maxlen:8"`
+		C *testFileHandle `xdr:"opt"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Bare",
+			Object: testFileHandle{1, 2, 3},
+			Bytes:  []byte{0, 0, 0, 3, 1, 2, 3, 0},
+		}, {
+			Name:       "Bare too long",
+			Object:     testFileHandle{1, 2, 3, 4, 5},
+			Bytes:      []byte{0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		}, {
+			Name:   "Struct",
+			Object: handles{A: testFileHandle{1}, B: testFileHandle{1, 2, 3, 4, 5}, C: &testFileHandle{2}},
+			Bytes: []byte{
+				0, 0, 0, 1, 1, 0, 0, 0,
+				0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0,
+				0, 0, 0, 1, 0, 0, 0, 1, 2, 0, 0, 0,
+			},
+		}, {
+			Name:       "Field too long",
+			Object:     handles{A: testFileHandle{1, 2, 3, 4, 5}},
+			Bytes:      []byte{0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		},
+	})
+
+	_, err := Marshal(testBadTagged(1))
+	assert.Error(t, err)
+}
+
+func TestFixedSlice(t *testing.T) {
+	type fixed struct {
+		Opaque []byte   `xdr:"len:3/opaque"`
+		Ints   []uint16 `xdr:"len:2"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Fixed",
+			Object: fixed{Opaque: []byte{1, 2, 3}, Ints: []uint16{4, 5}},
+			Bytes:  []byte{1, 2, 3, 0, 0, 0, 0, 4, 0, 0, 0, 5},
+		}, {
+			Name:       "Opaque too short",
+			Direction:  encodeTest,
+			Object:     fixed{Opaque: []byte{1, 2}, Ints: []uint16{4, 5}},
+			EncErrorIs: ErrLengthIncorrect,
+		}, {
+			Name:       "Elements too long",
+			Direction:  encodeTest,
+			Object:     fixed{Opaque: []byte{1, 2, 3}, Ints: []uint16{4, 5, 6}},
+			EncErrorIs: ErrLengthIncorrect,
+		}, {
+			Name:       "Truncated",
+			Direction:  decodeTest,
+			Object:     fixed{},
+			Bytes:      []byte{1, 2, 3, 0, 0, 0, 0, 4},
+			DecErrorIs: io.EOF,
+		},
+	})
+
+	t.Run("NoCopy", func(t *testing.T) {
+		buf := []byte{1, 2, 3, 0, 0, 0, 0, 4, 0, 0, 0, 5}
+		var v fixed
+		require.NoError(t, UnmarshalNoCopy(buf, &v))
+		assert.Equal(t, []uint16{4, 5}, v.Ints)
+
+		// v.Opaque should alias buf
+		buf[0] = 9
+		assert.Equal(t, []byte{9, 2, 3}, v.Opaque)
+
+		// Wherever the input is truncated, the error must match that of the copying path
+		for n := 0; n < len(buf); n++ {
+			var vc fixed
+			errCopy := Unmarshal(buf[0:n], &vc)
+			errNoCopy := UnmarshalNoCopy(buf[0:n], &v)
+			if assert.Error(t, errNoCopy, "Truncated to %d", n) {
+				assert.Equal(t, errCopy.Error(), errNoCopy.Error(), "Truncated to %d", n)
+			}
+		}
+
+		// A body which is present but truncated (including its padding) is unexpected
+		err := UnmarshalNoCopy(buf[0:3], &v)
+		assert.True(t, stderrors.Is(err, io.ErrUnexpectedEOF), "Expected io.ErrUnexpectedEOF, got %v", err)
+	})
+}
+
+func TestMapTags(t *testing.T) {
+	type attrs struct {
+		Values map[string][]byte `xdr:"maxlen:1/key=maxlen:3/opaque"`
+	}
+
+	type counts struct {
+		Counts map[string]uint32 `xdr:"key=maxlen:2"`
+	}
+
+	RunTestcases(t, []testcase{
+		{
+			Name:   "Tagged map",
+			Object: attrs{Values: map[string][]byte{"abc": {1, 2}}},
+			Bytes: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 3, 'a', 'b', 'c', 0,
+				0, 0, 0, 2, 1, 2, 0, 0,
+			},
+		}, {
+			Name:       "Too many entries",
+			Object:     attrs{Values: map[string][]byte{"a": nil, "b": nil}},
+			Bytes:      []byte{0, 0, 0, 2},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		}, {
+			Name:       "Key too long",
+			Object:     attrs{Values: map[string][]byte{"abcd": nil}},
+			Bytes:      []byte{0, 0, 0, 1, 0, 0, 0, 4, 'a', 'b', 'c', 'd', 0, 0, 0, 0},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		}, {
+			Name:   "Leading key tag",
+			Object: counts{Counts: map[string]uint32{"ab": 7}},
+			Bytes:  []byte{0, 0, 0, 1, 0, 0, 0, 2, 'a', 'b', 0, 0, 0, 0, 0, 7},
+		}, {
+			Name:       "Leading key tag too long",
+			Object:     counts{Counts: map[string]uint32{"abc": 7}},
+			Bytes:      []byte{0, 0, 0, 1, 0, 0, 0, 3, 'a', 'b', 'c', 0, 0, 0, 0, 7},
+			EncErrorIs: ErrLengthExceedsMax,
+			DecErrorIs: ErrLengthExceedsMax,
+		},
+	})
+
+	t.Run("Misplaced key tag", func(t *testing.T) {
+		var v struct {
+			S []string `xdr:"key=maxlen:2"`
+		}
+		_, err := Marshal(v)
+		assert.Error(t, err)
+	})
+}
@@ -151,6 +151,9 @@ type testcase struct {
 	// If returning true, it's a good idea to give a reason
 	ShouldSkip func(*testing.T, testDirection) (bool, string)
 
+	// The coder to use (defaults to DefaultCoder)
+	Coder Coder
+
 	// The object to marshal, or to use for comparison on unmarshalling
 	Object interface{}
 
@@ -194,6 +197,10 @@ func RunTestcases(t *testing.T, tcs []testcase) {
 			}
 		}
 
+		if tc.Coder == nil {
+			tc.Coder = &DefaultCoder
+		}
+
 		if tc.ShouldSkip == nil {
 			tc.ShouldSkip = func(*testing.T, testDirection) (bool, string) {
 				return false, ""
@@ -239,7 +246,7 @@ func RunTestcases(t *testing.T, tcs []testcase) {
 					} else {
 						w = newComparingWriter(t, tc.ReaderFactory(t, encodeTest))
 					}
-					e := NewEncoder(w)
+					e := tc.Coder.NewEncoder(w)
 					err := e.Encode(tc.Object)
 					if tc.EncErrorIs != nil {
 						require.Error(t, err, "Encoding should have returned an error")
@@ -264,7 +271,7 @@ func RunTestcases(t *testing.T, tcs []testcase) {
 					} else {
 						w = newComparingWriter(t, tc.ReaderFactory(t, encodeTest))
 					}
-					e := NewEncoder(w)
+					e := tc.Coder.NewEncoder(w)
 					v := reflect.ValueOf(tc.Object)
 					vp := reflect.New(v.Type())
 					vp.Elem().Set(v)
@@ -277,6 +284,24 @@ func RunTestcases(t *testing.T, tcs []testcase) {
 						w.(*comparingWriter).Assert()
 					}
 				})
+
+				t.Run("EncodedSize", func(t *testing.T) {
+					t.Parallel()
+					if skip, reason := tc.ShouldSkip(t, encodeTest); skip {
+						t.Skip(reason)
+					}
+
+					n, err := tc.Coder.EncodedSize(tc.Object)
+					if tc.EncErrorIs != nil {
+						require.Error(t, err, "EncodedSize should have returned an error")
+						require.Truef(t, errors.Is(err, tc.EncErrorIs), "Error expected to be %s, but was %s", tc.EncErrorIs, err)
+					} else {
+						require.NoError(t, err, "EncodedSize should succeed")
+						expected, err := ioutil.ReadAll(tc.ReaderFactory(t, encodeTest))
+						require.NoError(t, err)
+						assert.Equal(t, len(expected), n, "EncodedSize should match encoded length")
+					}
+				})
 			}
 
 			if tc.Direction != encodeTest {
@@ -287,7 +312,7 @@ func RunTestcases(t *testing.T, tcs []testcase) {
 					}
 
 					r := tc.ReaderFactory(t, decodeTest)
-					d := NewDecoder(r)
+					d := tc.Coder.NewDecoder(r)
 
 					// If tc.Object is of type T, then construct new(T)
 					tgtp := reflect.New(reflect.TypeOf(tc.Object)).Interface()
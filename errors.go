@@ -0,0 +1,109 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package xdr
+
+import "go.e43.eu/xdr/internal/errors"
+
+const (
+	// Array, slice, map, opaque or string longer than permitted by the schema
+	// (or by XDR, which limits lengths to 0xFFFFFFFF). Matched by LengthError
+	ErrLengthExceedsMax = errors.ErrLengthExceedsMax
+
+	// Length received was longer than the Go int type can represent (only on
+	// 32-bit platforms). Matched by LengthError
+	ErrLengthExceedsPlatformLimit = errors.ErrLengthExceedsPlatformLimit
+
+	// Length of fixed length object incorrect
+	ErrLengthIncorrect = errors.ErrLengthIncorrect
+
+	// Union switch value selected no arm, and the union has no default arm
+	ErrUnionSwitchArmUndefined = errors.ErrUnionSwitchArmUndefined
+
+	// Decode expected a pointer parameter
+	ErrNotPointer = errors.ErrNotPointer
+
+	// Invalid value for type
+	ErrInvalidValue = errors.ErrInvalidValue
+
+	// Pointer was unexpectedly nil
+	ErrNilPointer = errors.ErrNilPointer
+
+	// Decoding would exceed one of the decoder's resource limits (see Limits)
+	ErrLimitExceeded = errors.ErrLimitExceeded
+
+	// Padding bytes were not zero (only detected in strict mode)
+	ErrNonZeroPadding = errors.ErrNonZeroPadding
+
+	// Data remained after the decoded object (only detected in strict mode)
+	ErrTrailingData = errors.ErrTrailingData
+
+	// Integer out of range for the type it is being encoded as or decoded into.
+	// Matched by OverflowError
+	ErrIntegerOverflow = errors.ErrIntegerOverflow
+
+	// Value decoded for an enum is not one of its declared values. Matched by
+	// EnumValueError
+	ErrInvalidEnumValue = errors.ErrInvalidEnumValue
+
+	// Populated arms of a union do not agree with its switch, or more than one
+	// arm is populated (only detected for unions tagged `union:switch,infer`)
+	ErrUnionArmMismatch = errors.ErrUnionArmMismatch
+)
+
+// LimitKind identifies one of the resource limits in Limits
+type LimitKind = errors.LimitKind
+
+const (
+	// Limits.MaxBytes
+	LimitBytes = errors.LimitBytes
+	// Limits.MaxElements
+	LimitElements = errors.LimitElements
+	// Limits.MaxDepth
+	LimitDepth = errors.LimitDepth
+)
+
+// LimitError is returned when decoding would exceed a resource limit. It matches
+// ErrLimitExceeded
+type LimitError = errors.LimitError
+
+// InvalidTypeError is returned when attempting to encode or decode a type which
+// is unsupported
+type InvalidTypeError = errors.InvalidTypeError
+
+// InvalidTagForTypeError is returned when a tag is not valid for the type to
+// which it is applied
+type InvalidTagForTypeError = errors.InvalidTagForTypeError
+
+// OverflowError is returned when an integer is out of range. It matches
+// ErrIntegerOverflow
+type OverflowError = errors.OverflowError
+
+// EnumValueError is returned when an undeclared enum value is decoded. It
+// matches ErrInvalidEnumValue
+type EnumValueError = errors.EnumValueError
+
+// LengthError is returned when a variable length object is too long. It matches
+// ErrLengthExceedsMax or ErrLengthExceedsPlatformLimit as appropriate
+type LengthError = errors.LengthError
+
+// FieldError wraps errors which occurred inside compound objects, recording
+// the path to the object at fault. It unwraps to the underlying error
+type FieldError = errors.FieldError
+
+// PathElem is one element of FieldError.Path
+type PathElem = errors.PathElem
+
+// PathElemKind identifies the kind of a PathElem
+type PathElemKind = errors.PathElemKind
+
+const (
+	// A field of a struct (PathElem.Type, PathElem.Name)
+	PathField = errors.PathField
+	// The selected arm of a union (PathElem.Type, PathElem.Name, PathElem.Case)
+	PathUnionArm = errors.PathUnionArm
+	// An element of an array or slice (PathElem.Index)
+	PathIndex = errors.PathIndex
+	// An entry of a map (PathElem.Key)
+	PathMapKey = errors.PathMapKey
+)
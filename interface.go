@@ -17,10 +17,13 @@
 //     uint8, uint16, uint32 | unsigned int
 //                     int64 | hyper
 //                    uint64 | unsigned hyper
+//                       int | hyper (or int; see below)
+//             uint, uintptr | unsigned hyper (or unsigned int; see below)
 //                   float32 | float
 //                   float64 | double
 //                 complex64 | struct { float  Re; float  Im; }
 //                complex128 | struct { double Re; double Im; }
+//                 Quadruple | quadruple
 //                    string | string ident<>
 //                        *T | T (Go pointers are ignored)
 //                       []T | T ident<>
@@ -33,9 +36,22 @@
 // respectively)
 //
 // Go has no direct equivalent of XDR enumerations; therefore they should be defined
-// as type aliases for uint32:
+// as named integer types, and registered with Coder.RegisterEnum so that undeclared
+// values are rejected when decoding:
 //
-//     type MyEnum uint32
+//     type MyEnum int32
+//
+//     const (
+//         MY_A MyEnum = 1
+//         MY_B MyEnum = -2
+//     )
+//
+//     coder.RegisterEnum(MyEnum(0), map[string]int32{"MY_A": 1, "MY_B": -2})
+//
+//     func (e MyEnum) String() string { return coder.EnumString(e) }
+//
+// (XDR enumerations are signed, so int32 is the most natural type. Unregistered
+// types are encoded as plain integers, without any validation)
 //
 // There are some XDR types which cannot be expressed with just these; therefore
 // additional control is provided using the `xdr:"..."` struct tag:
@@ -44,10 +60,12 @@
 //     ----------------+--------------------------------
 //     T *ident        |  *T     `xdr:"opt"`
 //     T ident<N>      | []T     `xdr:"maxlen:N"`
+//     T ident[N]      | []T     `xdr:"len:N"`
 //     string ident[N] | string  `xdr:"len:N"`
 //     string ident<N> | string  `xdr:"maxlen:N"`
 //     opaque ident<>  | []byte  `xdr:"opaque"`
 //     opaque ident[N] | [N]byte `xdr:"opaque"`
+//     opaque ident[N] | []byte  `xdr:"len:N/opaque"`
 //     opaque ident<N> | []byte  `xdr:"maxlen:N/opaque"`
 //
 // Some structure field definitions contain multiple layers of types. For example, the type
@@ -87,16 +105,69 @@
 //         XDR: opaque ident[N]               opaque ident<N>
 //         Go:  ident [N]byte `xdr:"opaque"`  ident []byte `xdr:"maxlen:N/opaque"`
 //
+//     `int`, `hyper`
+//         Applied to an int, uint or uintptr, selects whether it is encoded as a 32-bit
+//         [unsigned] int or a 64-bit [unsigned] hyper. The default is `hyper`. Values which
+//         are out of range for the selected encoding (or, when decoding, for the Go type)
+//         cause an error matching ErrIntegerOverflow
+//
+//         Example: ident int `xdr:"int"`
+//
+//     `wrap`
+//         Applied to an int8, int16, uint8 or uint16, causes values which are out of range for
+//         the Go type to be truncated when decoded. By default, such values cause an error
+//         matching ErrIntegerOverflow. This is intended for legacy protocols which rely upon
+//         the truncation
+//
+//         Example: ident uint8 `xdr:"wrap"`
+//
 //     `len:N`
-//         Only applicable to strings, specifies that this string  is to be encoded as fixed width
+//         Only applicable to strings or slices, specifies that this string or slice is to be encoded
+//         as fixed width. When encoding, values of any other length cause ErrLengthIncorrect
 //
 //         Example: ident string `xdr:"len:16"`
 //
 //     `maxlen:N`
-//         Only applicable to strings or slices, specifies a maximum permitted length
+//         Only applicable to strings, slices or maps, specifies a maximum permitted length
 //
 //         Example: ident string `xdr:"maxlen:16"`
 //
+//     `key=T`
+//         Only applicable to maps, immediately following the map's tag (if any). Specifies the
+//         tag T to be applied to the map's key type. The key tag may only apply to a single layer
+//         (with the exception of `opaque`, as above)
+//
+//         Example: ident map[string][]byte `xdr:"maxlen:100/key=maxlen:255/opaque"`
+//
+//     `optlist`, `optlist:N`
+//         Only applicable to slices, specifies that the slice is encoded as a linked list of
+//         optional data (as is common in XDR protocols), i.e. each element is preceded by TRUE and
+//         the list is terminated by FALSE. If N is specified, it is the maximum permitted length.
+//
+//         XDR: struct entry { T value; entry *next; };  entry *ident;
+//         Go:  ident []T `xdr:"optlist"`
+//
+// A named type may declare a default tag for itself by implementing Tagger (with a value
+// receiver), which saves repeating the tag on every field of that type. The type's tag
+// applies at each layer for which the field's tag is empty:
+//
+//     type FileHandle []byte
+//
+//     func (FileHandle) XDRTag() string { return "maxlen:64/opaque" }
+//
+//     type T struct {
+//         A FileHandle                  // maxlen:64/opaque
+//         B FileHandle `xdr:"maxlen:8"` // maxlen:8/opaque
+//     }
+//
+// The fields of embedded structs are promoted: they are encoded inline, in declaration
+// order, as if they had been declared in the enclosing struct (and errors report them by
+// their own names). This is true for embedded pointers to structs also; encoding fails with
+// ErrNilPointer if such a pointer is nil, and decoding allocates it if required. (Decoding
+// cannot allocate a pointer to an unexported type). An embedded struct is instead treated as
+// an ordinary field if it has an `xdr` tag, is a union, or has a registered Codec or
+// implements Marshaler.
+//
 // Unions are slightly more tricky to define: Go does not provide a direct analogue for XDR unions.
 // Instead, define a struct where the fields are annotated with union tags:
 //
@@ -111,20 +182,44 @@
 //
 //     `union:switch`
 //          Specifies that the enclosing structure is a union, and that this field is the
-//          switch. The field must be of type int32, uint32 or bool.
+//          switch. The field must be a bool or an integer. As XDR requires, it is always encoded
+//          as a bool, int or unsigned int (so e.g. an int64 switch is encoded as an int, and values
+//          out of range for an int cause an error matching ErrIntegerOverflow).
 //
 //          Must be specified on the first field within the struct which is not skipped using
 //          `-`. If specified, every field must have a case tag
 //
+//     `union:switch,infer`
+//          As `union:switch`, but when encoding, the switch may be inferred from the populated
+//          arm (a non-nil pointer, interface, map or slice). If exactly one arm is populated and
+//          the switch is the zero value, it is encoded as the first case of that arm. If the
+//          switch is not the zero value and selects a different arm, or multiple arms are
+//          populated, encoding fails with ErrUnionArmMismatch. (Arms which are not of a nillable
+//          type are never considered populated, so can only be selected by the switch)
+//
 //     `union:A,B,C`, `union:true`, `union:false`, `union:default`
 //          Specifies which case(s) this field corresponds to. A/B/C are must be numeric values
-//          (unfortunately constants are not supported). `true` and `false` may be used instead
-//          for boolean switch fields. `default` specifies this is the default case (if no other
-//          case was encountered)
+//          or the names of constants registered with Coder.RegisterConstants, and may be negative
+//          (e.g. `union:-1`) if the switch is signed. Values out of range for the switch type, or
+//          for the int or unsigned int as which it is encoded, are an error. `true` and `false`
+//          may be used instead for boolean switch fields. `default` specifies this is the default
+//          case (if no other case was encountered)
 //
 // Union tags bind to the enclosing structure type; in this regard, they are a special case. They
 // may be followed by type-related specifiers like normal.
 //
+// Alternatively, a union may be represented by an interface type with one concrete type per
+// arm, registered using Coder.RegisterInterface:
+//
+//     union shape switch(int kind) {       | type Shape interface{ Area() float64 }
+//       case 1: circle  c;                 |
+//       case 2: polygon p;                 | coder.RegisterInterface((*Shape)(nil), map[int32]interface{}{
+//     };                                   |     1: Circle{}, 2: &Polygon{},
+//                                          | })
+//
+// Fields of a registered interface type may be tagged `opt`, in which case a nil value is
+// permitted.
+//
 // You can specify custom behaviour for your type using the Marshaler interface. If implemented,
 // it replaces the default behaviour. You can override behaviour for third party types by
 // implementing and regisering a Codec; see the documentation for that type and the Coder with
@@ -148,3 +243,6 @@ type Encoder = xdrinterfaces.Encoder
 
 // interface Decoder is the interface to the XDR decoder
 type Decoder = xdrinterfaces.Decoder
+
+// Limits specifies bounds on the resources which a Decoder may consume
+type Limits = xdrinterfaces.Limits
@@ -19,6 +19,17 @@ type Marshaler interface {
 	UnmarshalXDR(d Decoder) error
 }
 
+// interface Tagger is the interface implemented by a named type which specifies a
+// default XDR tag for itself, in the same syntax as the `xdr` struct tag. It applies
+// wherever the type is used, combined with any tag on the field; at each layer for
+// which the field's tag is empty, the type's tag is used.
+//
+// XDRTag is called on the zero value of the type, and so must be implemented with
+// a value receiver (and must not depend upon the value)
+type Tagger interface {
+	XDRTag() string
+}
+
 // interface Codec is the interface by which the marshalling of types which are
 // not natively supported may be defined.
 //
@@ -36,6 +47,24 @@ type Codec interface {
 	Decode(d Decoder, v reflect.Value) error
 }
 
+// Limits specifies bounds on the resources which a Decoder may consume. These
+// protect against hostile input which (for example) claims a huge length in order
+// to cause the decoder to allocate excessive amounts of memory.
+//
+// A zero value for any limit means that the corresponding resource is unlimited
+type Limits struct {
+	// MaxBytes bounds the total number of bytes which may be allocated to hold
+	// opaques, strings, and the contents of slices, maps and pointers
+	MaxBytes uint64
+
+	// MaxElements bounds the total number of elements of variable length arrays
+	// and maps which may be decoded
+	MaxElements uint64
+
+	// MaxDepth bounds the nesting depth of structures, unions, arrays and maps
+	MaxDepth int
+}
+
 // interface Coder is the top-level interface to the XDR library
 //
 // A coder (which may be safely used from multiple threads) provides the ability
@@ -45,9 +74,33 @@ type Coder interface {
 	// Marshals o into the returned buffer
 	Marshal(o interface{}) ([]byte, error)
 
+	// Marshals o, appending the result to dst, and returns the extended buffer.
+	// No intermediate buffer is used; dst is grown (as if by append) if it lacks
+	// sufficient capacity.
+	//
+	// On error, the returned slice has the length of dst (though its contents
+	// beyond that length, and its capacity, may have changed)
+	MarshalAppend(dst []byte, o interface{}) ([]byte, error)
+
+	// EncodedSize returns the number of bytes which marshalling o would produce,
+	// without encoding it. The same validation is performed as when marshalling,
+	// so an error is returned whenever Marshal would fail.
+	//
+	// For types which always have the same encoded size, this does not need to
+	// inspect o at all
+	EncodedSize(o interface{}) (int, error)
+
 	// Unmarshals buf into the object pointed to by op
 	Unmarshal(buf []byte, op interface{}) error
 
+	// Unmarshals buf into the object pointed to by op without copying opaque
+	// data or strings out of buf.
+	//
+	// Decoded []byte (opaque) values are sub-slices of buf, and decoded strings
+	// may share its memory. The caller must therefore keep buf alive and unmodified
+	// for as long as the decoded object (or any value taken from it) remains in use.
+	UnmarshalNoCopy(buf []byte, op interface{}) error
+
 	// Write marshals o into the passed writer
 	Write(w io.Writer, o interface{}) error
 
@@ -60,11 +113,90 @@ type Coder interface {
 	// Constructs a new decoder which reads from r
 	NewDecoder(r io.Reader) Decoder
 
+	// SetLimits sets the default resource limits applied to decoders constructed
+	// by this coder (including those used by Unmarshal and Read). These may be
+	// overridden for individual decoders with Decoder.SetLimits.
+	//
+	// This should be called before the coder is used; it is not safe to call
+	// concurrently with decoding
+	SetLimits(l Limits)
+
+	// SetStrict sets whether decoders constructed by this coder (including those
+	// used by Unmarshal and Read) operate in strict mode by default. This may be
+	// overridden for individual decoders with Decoder.SetStrict.
+	//
+	// This should be called before the coder is used; it is not safe to call
+	// concurrently with decoding
+	SetStrict(strict bool)
+
+	// SetSortedMaps sets whether encoders constructed by this coder (including those
+	// used by Marshal and Write) write map entries sorted by their encoded keys by
+	// default. This may be overridden for individual encoders with
+	// Encoder.SetSortedMaps.
+	//
+	// This should be called before the coder is used; it is not safe to call
+	// concurrently with encoding
+	SetSortedMaps(sorted bool)
+
+	// SetAllowUnknownEnums sets whether decoders constructed by this coder (including
+	// those used by Unmarshal and Read) accept enum values which were not declared
+	// when the enum was registered. This may be overridden for individual decoders
+	// with Decoder.SetAllowUnknownEnums.
+	//
+	// This should be called before the coder is used; it is not safe to call
+	// concurrently with decoding
+	SetAllowUnknownEnums(allow bool)
+
 	// Registers the codec. Panics if a codec is already registered for
 	// the type, or an attempt is made to register a codec for a type
 	// for which it is not permitted to register codecs.
 	RegisterCodec(template interface{}, c Codec)
 	RegisterCodecReflect(type_ reflect.Type, c Codec)
+
+	// RegisterConstants registers symbolic constants which may be used in place
+	// of numeric values in union case tags (e.g. `xdr:"union:NFS3_OK"`). Panics if
+	// a constant is already registered with a different value.
+	//
+	// Constants are resolved when the codec for a type is built, so they must be
+	// registered before any type which uses them is first encoded or decoded.
+	// Referencing an undefined constant is an error.
+	RegisterConstants(consts map[string]int64)
+
+	// RegisterEnum registers the integer type of template as an XDR enum with the
+	// specified names and values. Enums are always encoded as an int, and admit no
+	// tags other than `opt` (so e.g. `xdr:"hyper"` is an error). Decoding a value
+	// which is not declared fails with an error matching ErrInvalidEnumValue
+	// (unless unknown enum values are permitted; see SetAllowUnknownEnums).
+	//
+	// Panics if the type is not an integer type, is a primitive, any value does not
+	// fit in it, or a codec is already registered (or has already been built) for it.
+	RegisterEnum(template interface{}, values map[string]int32)
+	RegisterEnumReflect(type_ reflect.Type, values map[string]int32)
+
+	// RegisterInterface registers an interface type as a union, whose arms are each
+	// a concrete type implementing the interface. template must be a pointer to the
+	// interface (e.g. `(*MyInterface)(nil)`), and arms maps each discriminant to a
+	// value of the corresponding concrete type (e.g. `MyStruct{}` or `&MyStruct{}`).
+	//
+	// The interface is encoded as the discriminant (an int) for the dynamic type of
+	// its value, followed by the value. Encoding a nil interface fails with
+	// ErrNilPointer (unless tagged `opt`), and encoding or decoding a type or
+	// discriminant which is not registered fails with ErrUnionSwitchArmUndefined.
+	//
+	// Panics if an arm does not implement the interface, the same type is used for
+	// multiple arms, or a codec is already registered (or built) for the interface.
+	RegisterInterface(template interface{}, arms map[int32]interface{})
+	RegisterInterfaceReflect(type_ reflect.Type, arms map[int32]reflect.Type)
+
+	// EnumName returns the declared name of v, which must be of a registered enum
+	// type. Returns false if v is not of a registered enum type or not declared.
+	// Where several names share a value, the first in lexical order is returned
+	EnumName(v interface{}) (string, bool)
+
+	// EnumString returns the declared name of v if it has one (see EnumName), or
+	// otherwise a string of the form "Type(1234)". This is useful for implementing
+	// String methods on enum types
+	EnumString(v interface{}) string
 }
 
 // interface Encoder is the interface to the XDR encoder
@@ -97,6 +229,31 @@ type Encoder interface {
 	// This is for fixed length fields; no length prefix will be written
 	EncodeFixedOpaque(b []byte) error
 
+	// OpaqueWriter writes the length of an opaque of the specified length to the
+	// XDR encoder, then returns an io.WriteCloser through which the body should be
+	// written.
+	//
+	// Exactly length bytes must be written, and the writer *must* be closed before
+	// encoding anything further; Close() writes the padding, and returns
+	// ErrLengthIncorrect if too few bytes were written. Writing too many bytes
+	// also returns ErrLengthIncorrect.
+	//
+	// When the body is copied in from a reader (e.g. through io.Copy), the reader
+	// is checked for excess data by reading one byte beyond the end of the body.
+	// If the reader implements io.ByteScanner, that byte is unread; otherwise it
+	// is consumed.
+	OpaqueWriter(length uint32) (io.WriteCloser, error)
+
+	// FixedOpaqueWriter returns an io.WriteCloser through which the body of a fixed
+	// length opaque of the specified length should be written, in the same fashion
+	// as OpaqueWriter()
+	FixedOpaqueWriter(length uint32) io.WriteCloser
+
+	// EncodeOpaqueFrom writes an opaque of length n to the XDR encoder, whose body
+	// is read from r. If r contains fewer than n bytes, io.ErrUnexpectedEOF is
+	// returned
+	EncodeOpaqueFrom(r io.Reader, n uint32) error
+
 	// EncodeString writes a string to the XDR encoder
 	EncodeString(s string) error
 
@@ -108,6 +265,12 @@ type Encoder interface {
 
 	// EncodeValue encodes an object to the XDR encoder (via reflection)
 	EncodeValue(v reflect.Value) error
+
+	// SetSortedMaps enables or disables deterministic map encoding. By default,
+	// map entries are written in Go's (random) map iteration order. When enabled,
+	// entries are instead written in ascending (bytewise) order of their XDR
+	// encoded keys, so that encoding the same map always produces the same bytes
+	SetSortedMaps(sorted bool)
 }
 
 // interface Decoder is the interface to the XDR decoder
@@ -125,7 +288,9 @@ type Decoder interface {
 	DecodeDouble() (float64, error)
 
 	// DecodeOpaque reads an opaque of maximum length maxLen from the XDR decoder
-	// A newly allocated buffer is returned.
+	// A newly allocated buffer is returned, unless the decoder is operating in
+	// zero-copy mode (see Coder.UnmarshalNoCopy), in which case the returned slice
+	// refers to the decoder's input buffer.
 	DecodeOpaque(maxLen int) ([]byte, error)
 
 	// OpaqueReader returns an io.Reader which reads the body of the opaque from the
@@ -135,6 +300,20 @@ type Decoder interface {
 	// to just exhaust the stream; Close() must also be called to consume padding.
 	OpaqueReader(maxLen uint32) (uint32, io.ReadCloser, error)
 
+	// ArrayIter reads the length of a variable length array (of maximum length
+	// maxLen) from the XDR decoder, returning it along with a function which
+	// decodes the next element of the array into *op. Once all elements have been
+	// decoded, the function returns io.EOF. If decoding an element fails, the function
+	// returns that error, and continues to do so on every subsequent call (as the
+	// position in the stream is no longer known).
+	//
+	// The array is subject to the decoder's limits: its length counts towards
+	// MaxElements, and it counts as a level of nesting until iteration finishes.
+	//
+	// As with OpaqueReader, the array must be consumed entirely before reading
+	// further.
+	ArrayIter(maxLen uint32) (uint32, func(op interface{}) error, error)
+
 	// DecodeFixedOpaque reads a fixed-size opaque into the passed buffer
 	DecodeFixedOpaque(buf []byte) error
 
@@ -154,4 +333,37 @@ type Decoder interface {
 	// DecodeValue reads an object from the stream
 	// v must be a settable value (v.CanSet() is true)
 	DecodeValue(v reflect.Value) error
+
+	// SetLimits sets the resource limits for this decoder, replacing those inherited
+	// from the Coder. Usage is accumulated across everything decoded; calling
+	// SetLimits also resets the decoder's usage counters.
+	//
+	// When decoding would exceed a limit, an error matching ErrLimitExceeded is returned
+	SetLimits(l Limits)
+
+	// SetStrict enables or disables strict mode. RFC 4506 requires that the padding
+	// following opaques and strings is zero, but by default this is not checked. In
+	// strict mode, non-zero padding causes decoding to fail with ErrNonZeroPadding,
+	// and Unmarshal fails with ErrTrailingData if any data is left over after the
+	// object.
+	//
+	// Booleans with values other than 0 or 1 are rejected regardless of mode
+	SetStrict(strict bool)
+
+	// SetAllowUnknownEnums sets whether enum values which were not declared when
+	// the enum was registered are accepted, replacing the setting inherited from
+	// the Coder. This is useful for forward compatibility with peers using a
+	// newer version of a protocol
+	SetAllowUnknownEnums(allow bool)
+
+	// Skip consumes a value of type t from the stream without decoding it. Fixed
+	// size values are skipped in one go (unless they contain padding which must be
+	// checked in strict mode), and opaques and strings are skipped by
+	// their length without being stored. Nested values are validated only so far
+	// as is necessary to find their end
+	Skip(t reflect.Type) error
+
+	// Offset returns the number of bytes which the decoder has consumed from its
+	// input. Decode errors record the offset at which they occurred
+	Offset() int64
 }
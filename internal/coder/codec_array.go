@@ -34,7 +34,7 @@ type arrayCodec struct {
 func makeArrayCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
 	switch {
 	case tag.Kind() != tags.Noop:
-		return &errorCodec{errors.InvalidTagForTypeError{t, tag}}
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
 	case tag.Next().Kind() == tags.Opaque:
 		c := new(opaqueArrayCodec)
 		c.bufs.New = newForT(t)
@@ -90,16 +90,22 @@ func (c *opaqueArrayCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) erro
 func (c *arrayCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 	for i, l := 0, v.Len(); i < l; i++ {
 		if err := c.elem.Encode(e, v.Index(i)); err != nil {
-			return err
+			return errors.WithIndexError(err, -1, i)
 		}
 	}
 	return nil
 }
 
 func (c *arrayCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	for i, l := 0, v.Len(); i < l; i++ {
+		off := d.Offset()
 		if err := c.elem.Decode(d, v.Index(i)); err != nil {
-			return err
+			return errors.WithIndexError(err, off, i)
 		}
 	}
 	return nil
@@ -120,16 +126,35 @@ type sliceCodec struct {
 	origMax uint32
 }
 
+// fixedOpaqueSliceCodec and fixedSliceCodec handle slices with the `len:N` tag,
+// which are encoded as fixed length arrays
+type fixedOpaqueSliceCodec struct {
+	len int
+}
+
+var _ xCodec = &fixedOpaqueSliceCodec{}
+
+type fixedSliceCodec struct {
+	elem xCodec
+	t    reflect.Type
+	len  int
+	size uintptr
+}
+
 func makeSliceCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
 	maxlen := ^uint32(0)
 
 	switch tag.Kind() {
+	case tags.Len:
+		return makeFixedSliceCodec(cr, t, tag)
 	case tags.MaxLen:
 		maxlen = tag.OnlyValue()
+	case tags.OptList:
+		return makeOptListCodec(cr, t, tag)
 	case tags.Noop:
 		// Nothing
 	default:
-		return &errorCodec{errors.InvalidTagForTypeError{t, tag}}
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
 	}
 
 	// Cap lengths at maxInt
@@ -155,10 +180,30 @@ func makeSliceCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Co
 	}
 }
 
+func makeFixedSliceCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
+	len := tag.OnlyValue()
+	if uint64(len) > uint64(maxInt) {
+		// This can never work
+		return &errorCodec{errors.LengthError{Actual: uint64(len), Max: uint64(len), Offset: -1}}
+	}
+
+	switch {
+	case tag.Next().Kind() == tags.Opaque:
+		return &fixedOpaqueSliceCodec{int(len)}
+	default:
+		return &fixedSliceCodec{
+			elem: cr.getCodec(t.Elem(), tag.Next()),
+			t:    t,
+			len:  int(len),
+			size: t.Elem().Size(),
+		}
+	}
+}
+
 func (c *opaqueSliceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 	s := v.Bytes()
 	if len(s) > c.maxlen {
-		return errors.LengthError{uint64(len(s)), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(len(s)), Max: uint64(c.origMax), Offset: -1}
 	}
 
 	return e.EncodeOpaque(s)
@@ -170,10 +215,33 @@ func (c *opaqueSliceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) erro
 	return err
 }
 
+// initialLen returns the length of the slice we should initially allocate when
+// decoding a slice of length l. This is l, unless that would be larger than
+// allocChunk bytes
+func (c *sliceCodec) initialLen(l int) int {
+	if c.size != 0 && uintptr(l) > allocChunk/c.size {
+		return int(allocChunk / c.size)
+	}
+	return l
+}
+
+// growSlice returns a new slice (of type t) containing the contents of s, with its
+// length doubled but no greater than max
+func growSlice(t reflect.Type, s reflect.Value, max int) reflect.Value {
+	l := 2 * s.Len()
+	if l > max {
+		l = max
+	}
+
+	ns := reflect.MakeSlice(t, l, l)
+	reflect.Copy(ns, s)
+	return ns
+}
+
 func (c *sliceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 	l := v.Len()
 	if uint64(l) > uint64(c.maxlen) {
-		return errors.LengthError{uint64(l), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(l), Max: uint64(c.origMax), Offset: -1}
 	}
 
 	if err := e.EncodeUnsignedInt(uint32(l)); err != nil {
@@ -182,13 +250,18 @@ func (c *sliceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 
 	for i := 0; i < l; i++ {
 		if err := c.elem.Encode(e, v.Index(i)); err != nil {
-			return err
+			return errors.WithIndexError(err, -1, i)
 		}
 	}
 	return nil
 }
 
 func (c *sliceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	l, err := d.DecodeUnsignedInt()
 	switch {
 	case err != nil:
@@ -198,14 +271,101 @@ func (c *sliceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
 		v.Set(reflect.Zero(c.t))
 		return nil
 	case l > uint32(c.maxlen):
-		return errors.LengthError{uint64(l), uint64(c.origMax)}
+		return decodeLengthError(d, uint64(l), uint64(c.origMax))
 	}
 
-	v.Set(reflect.MakeSlice(c.t, int(l), int(l)))
+	if err := decodeElements(d, l, c.size); err != nil {
+		return err
+	}
 
-	for i := uint32(0); i < l; i++ {
-		if err := c.elem.Decode(d, v.Index(int(i))); err != nil {
-			return err
+	n := int(l)
+	il := c.initialLen(n)
+	v.Set(reflect.MakeSlice(c.t, il, il))
+
+	for i := 0; i < n; i++ {
+		if i == v.Len() {
+			v.Set(growSlice(c.t, v, n))
+		}
+
+		off := d.Offset()
+		if err := c.elem.Decode(d, v.Index(i)); err != nil {
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
+		}
+	}
+	return nil
+}
+
+func (c *fixedOpaqueSliceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	return c.encode(e, v.Bytes())
+}
+
+func (c *fixedOpaqueSliceCodec) encode(e xdrinterfaces.Encoder, s []byte) error {
+	if len(s) != c.len {
+		return errors.ErrLengthIncorrect
+	}
+	return e.EncodeFixedOpaque(s)
+}
+
+func (c *fixedOpaqueSliceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	s, err := c.decode(d)
+	v.SetBytes(s)
+	return err
+}
+
+// decode reads the slice body. In zero-copy mode, the result refers to the
+// decoder's input buffer
+//
+// In either mode, input which ends before the body is reported as io.EOF (as there
+// is no length prefix, nothing of the value has been read), and input which ends
+// part way through the body or its padding as io.ErrUnexpectedEOF
+func (c *fixedOpaqueSliceCodec) decode(d xdrinterfaces.Decoder) ([]byte, error) {
+	if dd, ok := d.(*decoder); ok && dd.noCopy {
+		b, err := dd.src.next((c.len + 3) & ^3)
+		if err != nil {
+			return nil, err
+		}
+		if err := dd.checkPadding(b[c.len:]); err != nil {
+			return nil, err
+		}
+		return b[0:c.len:c.len], nil
+	}
+
+	if err := decodeAllocate(d, uintptr(c.len)); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, c.len)
+	return b, d.DecodeFixedOpaque(b)
+}
+
+func (c *fixedSliceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	if v.Len() != c.len {
+		return errors.ErrLengthIncorrect
+	}
+
+	for i := 0; i < c.len; i++ {
+		if err := c.elem.Encode(e, v.Index(i)); err != nil {
+			return errors.WithIndexError(err, -1, i)
+		}
+	}
+	return nil
+}
+
+func (c *fixedSliceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
+	if err := decodeElements(d, uint32(c.len), c.size); err != nil {
+		return err
+	}
+
+	v.Set(reflect.MakeSlice(c.t, c.len, c.len))
+	for i := 0; i < c.len; i++ {
+		off := d.Offset()
+		if err := c.elem.Decode(d, v.Index(i)); err != nil {
+			return errors.WithIndexError(err, off, i)
 		}
 	}
 	return nil
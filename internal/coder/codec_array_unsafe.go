@@ -36,16 +36,22 @@ func (c *opaqueArrayCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointe
 func (c *arrayCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
 	for i := 0; i < c.len; i++ {
 		if err := c.elem.encodeUnsafe(e, unsafe.Pointer(uintptr(p)+uintptr(i)*c.size)); err != nil {
-			return err
+			return errors.WithIndexError(err, -1, i)
 		}
 	}
 	return nil
 }
 
 func (c *arrayCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	for i := 0; i < c.len; i++ {
+		off := d.Offset()
 		if err := c.elem.decodeUnsafe(d, unsafe.Pointer(uintptr(p)+uintptr(i)*c.size)); err != nil {
-			return err
+			return errors.WithIndexError(err, off, i)
 		}
 	}
 	return nil
@@ -54,7 +60,7 @@ func (c *arrayCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) err
 func (c *opaqueSliceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
 	s := *(*[]byte)(p)
 	if len(s) > c.maxlen {
-		return errors.LengthError{uint64(len(s)), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(len(s)), Max: uint64(c.origMax), Offset: -1}
 	}
 
 	return e.EncodeOpaque(s)
@@ -69,7 +75,7 @@ func (c *opaqueSliceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointe
 func (c *sliceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
 	sh := ((*reflect.SliceHeader)(p))
 	if uint64(sh.Len) > uint64(c.maxlen) {
-		return errors.LengthError{uint64(sh.Len), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(sh.Len), Max: uint64(c.origMax), Offset: -1}
 	}
 
 	if err := e.EncodeUnsignedInt(uint32(sh.Len)); err != nil {
@@ -79,13 +85,18 @@ func (c *sliceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) err
 	pd := unsafe.Pointer(sh.Data)
 	for i := 0; i < sh.Len; i++ {
 		if err := c.elem.encodeUnsafe(e, unsafe.Pointer(uintptr(pd)+uintptr(i)*c.size)); err != nil {
-			return err
+			return errors.WithIndexError(err, -1, i)
 		}
 	}
 	return nil
 }
 
 func (c *sliceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	l, err := d.DecodeUnsignedInt()
 	switch {
 	case err != nil:
@@ -98,18 +109,85 @@ func (c *sliceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) err
 		sh.Data = 0
 		return nil
 	case l > uint32(c.maxlen):
-		return errors.LengthError{uint64(l), uint64(c.origMax)}
+		return decodeLengthError(d, uint64(l), uint64(c.origMax))
 	}
 
-	rp := reflect.NewAt(c.t, p)
-	rp.Elem().Set(reflect.MakeSlice(c.t, int(l), int(l)))
+	if err := decodeElements(d, l, c.size); err != nil {
+		return err
+	}
+
+	n := int(l)
+	il := c.initialLen(n)
+	rv := reflect.NewAt(c.t, p).Elem()
+	rv.Set(reflect.MakeSlice(c.t, il, il))
 
 	sh := ((*reflect.SliceHeader)(p))
 	pd := unsafe.Pointer(sh.Data)
-	for i := 0; i < sh.Len; i++ {
+	for i := 0; i < n; i++ {
+		if i == sh.Len {
+			rv.Set(growSlice(c.t, rv, n))
+			pd = unsafe.Pointer(sh.Data)
+		}
+
+		off := d.Offset()
 		if err := c.elem.decodeUnsafe(d, unsafe.Pointer(uintptr(pd)+uintptr(i)*c.size)); err != nil {
-			return err
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
 		}
 	}
 	return nil
 }
+
+func (c *fixedOpaqueSliceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	return c.encode(e, *(*[]byte)(p))
+}
+
+func (c *fixedOpaqueSliceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	s, err := c.decode(d)
+	*(*[]byte)(p) = s
+	return err
+}
+
+func (c *fixedSliceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	sh := ((*reflect.SliceHeader)(p))
+	if sh.Len != c.len {
+		return errors.ErrLengthIncorrect
+	}
+
+	pd := unsafe.Pointer(sh.Data)
+	for i := 0; i < c.len; i++ {
+		if err := c.elem.encodeUnsafe(e, unsafe.Pointer(uintptr(pd)+uintptr(i)*c.size)); err != nil {
+			return errors.WithIndexError(err, -1, i)
+		}
+	}
+	return nil
+}
+
+func (c *fixedSliceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
+	if err := decodeElements(d, uint32(c.len), c.size); err != nil {
+		return err
+	}
+
+	reflect.NewAt(c.t, p).Elem().Set(reflect.MakeSlice(c.t, c.len, c.len))
+
+	pd := unsafe.Pointer(((*reflect.SliceHeader)(p)).Data)
+	for i := 0; i < c.len; i++ {
+		off := d.Offset()
+		if err := c.elem.decodeUnsafe(d, unsafe.Pointer(uintptr(pd)+uintptr(i)*c.size)); err != nil {
+			return errors.WithIndexError(err, off, i)
+		}
+	}
+	return nil
+}
+
+func (c *optListCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	return c.Encode(e, reflect.NewAt(c.t, p).Elem())
+}
+
+func (c *optListCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	return c.Decode(d, reflect.NewAt(c.t, p).Elem())
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+)
+
+// enumCodec handles integer types registered as XDR enums with Coder.RegisterEnum.
+// They are encoded as an XDR int.
+//
+// When decoding, values which were not declared cause an EnumValueError unless
+// the decoder permits unknown enum values. Undeclared values are always permitted
+// when encoding (so that values which were decoded may be passed back unchanged),
+// though values which cannot be represented as an int cause an OverflowError
+type enumCodec struct {
+	t     reflect.Type
+	names map[int32]string
+
+	// Names of the declared values, in ascending order of value
+	declared []string
+}
+
+func (cr *Coder) RegisterEnum(template interface{}, values map[string]int32) {
+	cr.RegisterEnumReflect(reflect.TypeOf(template), values)
+}
+
+func (cr *Coder) RegisterEnumReflect(t reflect.Type, values map[string]int32) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(fmt.Sprintf("Attempt to register enum for type %s which is not an integer", t))
+	}
+
+	if _, isPrimitive := prohibitedPrimitives[t]; isPrimitive {
+		panic(fmt.Sprintf("Attempt to register enum for primitive %s is prohibited", t))
+	}
+
+	// Iterate in sorted order so that, where multiple names share a value,
+	// the name we choose for it is deterministic
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := &enumCodec{t: t, names: make(map[int32]string, len(values))}
+	v := reflect.New(t).Elem()
+	for _, name := range names {
+		val := values[name]
+		if c.overflows(v, int64(val)) {
+			panic(fmt.Sprintf("Value %d of enum constant '%s' does not fit in %s", val, name, t))
+		}
+
+		if _, exists := c.names[val]; !exists {
+			c.names[val] = name
+		}
+	}
+
+	sort.SliceStable(names, func(i, j int) bool { return values[names[i]] < values[names[j]] })
+	c.declared = names
+
+	xt := xType{t, ""}
+	existing, found := cr.knownCodecs.LoadOrStore(xt, toXCodec(c, t))
+	if found {
+		panic(fmt.Sprintf("Attempt to register enum for type '%s' but '%s' is already registered", t, existing))
+	}
+	cr.knownEnums.Store(t, c)
+}
+
+// lookupEnum returns the codec of the registered enum type t, or nil if t is
+// not a registered enum type
+func (cr *Coder) lookupEnum(t reflect.Type) *enumCodec {
+	c, ok := cr.knownEnums.Load(t)
+	if !ok {
+		return nil
+	}
+	return c.(*enumCodec)
+}
+
+// EnumName returns the name of the value of the registered enum type v, or false
+// if v is not of a registered enum type or is not a declared value
+func (cr *Coder) EnumName(v interface{}) (string, bool) {
+	c := cr.lookupEnum(reflect.TypeOf(v))
+	if c == nil {
+		return "", false
+	}
+	return c.name(reflect.ValueOf(v))
+}
+
+// name returns the name of the value v, or false if it is not a declared value
+func (c *enumCodec) name(v reflect.Value) (string, bool) {
+	i, ok := c.wireValue(v)
+	if !ok {
+		return "", false
+	}
+
+	name, ok := c.names[i]
+	return name, ok
+}
+
+// EnumString returns a string representation of v, using its name if it is a
+// declared value of a registered enum type, or of the form "Type(1234)" otherwise
+func (cr *Coder) EnumString(v interface{}) string {
+	if name, ok := cr.EnumName(v); ok {
+		return name
+	}
+	return fmt.Sprintf("%T(%v)", v, v)
+}
+
+// overflows returns whether i can not be represented in v
+func (c *enumCodec) overflows(v reflect.Value, i int64) bool {
+	switch c.t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return i < 0 || v.OverflowUint(uint64(i))
+	default:
+		return v.OverflowInt(i)
+	}
+}
+
+// wireValue returns the value of v as it is encoded, or false if it cannot be
+// represented as an XDR int
+func (c *enumCodec) wireValue(v reflect.Value) (int32, bool) {
+	switch c.t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		return int32(u), u <= math.MaxInt32
+	default:
+		i := v.Int()
+		return int32(i), i >= math.MinInt32 && i <= math.MaxInt32
+	}
+}
+
+func (c *enumCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	i, ok := c.wireValue(v)
+	if !ok {
+		return errors.OverflowError{Value: v.Interface(), Type: "int"}
+	}
+	return e.EncodeInt(i)
+}
+
+func (c *enumCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	i, err := d.DecodeInt()
+	if err != nil {
+		return err
+	}
+
+	if _, declared := c.names[i]; !declared {
+		if dd, ok := d.(*decoder); !ok || !dd.allowUnknownEnums {
+			return errors.EnumValueError{Value: i, Type: c.t.String(), Declared: c.declared}
+		}
+	}
+
+	if c.overflows(v, int64(i)) {
+		return errors.OverflowError{Value: int64(i), Type: c.t.String()}
+	}
+
+	switch c.t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(i))
+	default:
+		v.SetInt(int64(i))
+	}
+	return nil
+}
+
+func (c *enumCodec) String() string {
+	return fmt.Sprintf("enum %s", c.t)
+}
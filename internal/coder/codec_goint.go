@@ -0,0 +1,206 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"math"
+	"reflect"
+
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+	"go.e43.eu/xdr/internal/tags"
+)
+
+// goIntCodec handles Go's platform dependent int type, and goUintCodec its uint
+// and uintptr types. By default they are encoded as a [unsigned] hyper; if the
+// `int` tag is specified they are instead encoded as an [unsigned] int
+//
+// Values which cannot be represented in the chosen encoding (or, when decoding,
+// in the Go type on this platform) cause an OverflowError
+type goIntCodec struct {
+	hyper bool
+}
+
+type goUintCodec struct {
+	hyper   bool
+	uintptr bool
+}
+
+func makeGoIntCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
+	hyper := true
+	switch tag.Kind() {
+	case tags.Noop, tags.Hyper:
+		// Default
+	case tags.Int:
+		hyper = false
+	default:
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
+	}
+
+	if !tag.Next().Empty() {
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
+	}
+
+	switch t.Kind() {
+	case reflect.Int:
+		return goIntCodec{hyper}
+	case reflect.Uintptr:
+		return goUintCodec{hyper, true}
+	default: // reflect.Uint
+		return goUintCodec{hyper, false}
+	}
+}
+
+func (c goIntCodec) typeName() string {
+	if c.hyper {
+		return "hyper"
+	}
+	return "int"
+}
+
+func (c goIntCodec) encode(e xdrinterfaces.Encoder, i int64) error {
+	switch {
+	case c.hyper:
+		return e.EncodeHyper(i)
+	case i < math.MinInt32 || i > math.MaxInt32:
+		return errors.OverflowError{Value: i, Type: c.typeName()}
+	default:
+		return e.EncodeInt(int32(i))
+	}
+}
+
+func (c goIntCodec) decode(d xdrinterfaces.Decoder) (int, error) {
+	var i int64
+	if c.hyper {
+		h, err := d.DecodeHyper()
+		if err != nil {
+			return 0, err
+		}
+		i = h
+	} else {
+		i32, err := d.DecodeInt()
+		if err != nil {
+			return 0, err
+		}
+		i = int64(i32)
+	}
+
+	if int64(int(i)) != i {
+		return 0, errors.OverflowError{Value: i, Type: "int"}
+	}
+	return int(i), nil
+}
+
+func (c goIntCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	return c.encode(e, v.Int())
+}
+
+func (c goIntCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	i, err := c.decode(d)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(i))
+	return nil
+}
+
+func (c goUintCodec) typeName() string {
+	if c.hyper {
+		return "unsigned hyper"
+	}
+	return "unsigned int"
+}
+
+func (c goUintCodec) goTypeName() string {
+	if c.uintptr {
+		return "uintptr"
+	}
+	return "uint"
+}
+
+func (c goUintCodec) encode(e xdrinterfaces.Encoder, u uint64) error {
+	switch {
+	case c.hyper:
+		return e.EncodeUnsignedHyper(u)
+	case u > math.MaxUint32:
+		return errors.OverflowError{Value: u, Type: c.typeName()}
+	default:
+		return e.EncodeUnsignedInt(uint32(u))
+	}
+}
+
+func (c goUintCodec) decode(d xdrinterfaces.Decoder) (uint64, error) {
+	var u uint64
+	if c.hyper {
+		h, err := d.DecodeUnsignedHyper()
+		if err != nil {
+			return 0, err
+		}
+		u = h
+	} else {
+		u32, err := d.DecodeUnsignedInt()
+		if err != nil {
+			return 0, err
+		}
+		u = uint64(u32)
+	}
+
+	if (c.uintptr && uint64(uintptr(u)) != u) || (!c.uintptr && uint64(uint(u)) != u) {
+		return 0, errors.OverflowError{Value: u, Type: c.goTypeName()}
+	}
+	return u, nil
+}
+
+func (c goUintCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	return c.encode(e, v.Uint())
+}
+
+func (c goUintCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	u, err := c.decode(d)
+	if err != nil {
+		return err
+	}
+	v.SetUint(u)
+	return nil
+}
+
+// wireSize returns the encoded size of values
+func (c goIntCodec) wireSize() int {
+	if c.hyper {
+		return 8
+	}
+	return 4
+}
+
+func (c goUintCodec) wireSize() int {
+	if c.hyper {
+		return 8
+	}
+	return 4
+}
+
+// Encoding can fail when using the `int` encoding, so only the `hyper` encoding is fixed size.
+// (On 32-bit platforms, decoding a hyper can also fail, but that does not affect
+// the encoded size)
+func (c goIntCodec) fixedSize() int {
+	if c.hyper {
+		return 8
+	}
+	return -1
+}
+
+func (c goUintCodec) fixedSize() int {
+	if c.hyper {
+		return 8
+	}
+	return -1
+}
+
+func (c goIntCodec) skip(d *decoder, t reflect.Type) error {
+	return d.discard(c.wireSize())
+}
+
+func (c goUintCodec) skip(d *decoder, t reflect.Type) error {
+	return d.discard(c.wireSize())
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"fmt"
+	"reflect"
+
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+)
+
+// interfaceCodec handles interface types registered with Coder.RegisterInterface.
+// These are encoded as a union, whose discriminant (an int) selects the concrete
+// type of the body
+type interfaceCodec struct {
+	cr    *Coder
+	t     reflect.Type
+	types map[int32]reflect.Type
+	discs map[reflect.Type]int32
+}
+
+func (cr *Coder) RegisterInterface(template interface{}, arms map[int32]interface{}) {
+	t := reflect.TypeOf(template)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("Attempt to register interface using template of type %s which is not a pointer to an interface", t))
+	}
+
+	types := make(map[int32]reflect.Type, len(arms))
+	for disc, arm := range arms {
+		types[disc] = reflect.TypeOf(arm)
+	}
+	cr.RegisterInterfaceReflect(t.Elem(), types)
+}
+
+func (cr *Coder) RegisterInterfaceReflect(t reflect.Type, arms map[int32]reflect.Type) {
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("Attempt to register interface for type %s which is not an interface", t))
+	}
+
+	c := &interfaceCodec{
+		cr:    cr,
+		t:     t,
+		types: make(map[int32]reflect.Type, len(arms)),
+		discs: make(map[reflect.Type]int32, len(arms)),
+	}
+
+	for disc, at := range arms {
+		switch {
+		case at == nil:
+			panic(fmt.Sprintf("Attempt to register nil type as arm %d of interface %s", disc, t))
+		case !at.Implements(t):
+			panic(fmt.Sprintf("Attempt to register type %s which does not implement %s as arm %d", at, t, disc))
+		}
+
+		if existing, found := c.discs[at]; found {
+			panic(fmt.Sprintf("Attempt to register type %s as arms %d and %d of interface %s", at, existing, disc, t))
+		}
+		c.types[disc] = at
+		c.discs[at] = disc
+	}
+
+	xt := xType{t, ""}
+	existing, found := cr.knownCodecs.LoadOrStore(xt, toXCodec(c, t))
+	if found {
+		panic(fmt.Sprintf("Attempt to register interface '%s' but '%s' is already registered", t, existing))
+	}
+}
+
+func (c *interfaceCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return errors.ErrNilPointer
+	}
+
+	body := v.Elem()
+	disc, ok := c.discs[body.Type()]
+	if !ok {
+		return errors.ErrUnionSwitchArmUndefined
+	}
+
+	if err := e.EncodeInt(disc); err != nil {
+		return err
+	}
+
+	if err := c.cr.getBaseCodec(body.Type()).Encode(e, body); err != nil {
+		return errors.WithUnionArmError(err, -1, c.t.Name(), body.Type().String(), disc, "")
+	}
+	return nil
+}
+
+func (c *interfaceCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
+	disc, err := d.DecodeInt()
+	if err != nil {
+		return err
+	}
+
+	at, ok := c.types[disc]
+	if !ok {
+		return errors.ErrUnionSwitchArmUndefined
+	}
+
+	if err := decodeAllocate(d, at.Size()); err != nil {
+		return err
+	}
+
+	off := d.Offset()
+	body := reflect.New(at).Elem()
+	if err := c.cr.getBaseCodec(at).Decode(d, body); err != nil {
+		return errors.WithUnionArmError(unexpectedEOF(err), off, c.t.Name(), at.String(), disc, "")
+	}
+	v.Set(body)
+	return nil
+}
+
+func (c *interfaceCodec) String() string {
+	return fmt.Sprintf("interface %s", c.t)
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+// +build !nounsafe
+
+package coder
+
+import (
+	"reflect"
+	"unsafe"
+
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+)
+
+func (c *interfaceCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	return c.Encode(e, reflect.NewAt(c.t, p).Elem())
+}
+
+func (c *interfaceCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	return c.Decode(d, reflect.NewAt(c.t, p).Elem())
+}
@@ -4,7 +4,9 @@
 package coder
 
 import (
+	"bytes"
 	"reflect"
+	"sort"
 
 	xdrinterfaces "go.e43.eu/xdr/interfaces"
 	"go.e43.eu/xdr/internal/errors"
@@ -21,14 +23,17 @@ type mapCodec struct {
 
 func makeMapCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
 	maxlen := ^uint32(0)
+	var keyTag tags.XDRTag
 
 	switch tag.Kind() {
 	case tags.MaxLen:
 		maxlen = tag.OnlyValue()
+	case tags.MapKey:
+		maxlen, keyTag = tag.KeyTag()
 	case tags.Noop:
 		// Nothing
 	default:
-		return &errorCodec{errors.InvalidTagForTypeError{t, tag}}
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
 	}
 
 	// Cap lengths at maxInt
@@ -41,7 +46,7 @@ func makeMapCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Code
 	}
 
 	return &mapCodec{
-		keyCodec:   cr.getCodec(t.Key(), nil),
+		keyCodec:   cr.getCodec(t.Key(), keyTag),
 		valueCodec: cr.getCodec(t.Elem(), tag.Next()),
 		t:          t,
 		kt:         t.Key(),
@@ -51,54 +56,182 @@ func makeMapCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Code
 	}
 }
 
+// keyInterface returns the value of the map key k for use in error paths
+func keyInterface(k reflect.Value) interface{} {
+	if k.CanInterface() {
+		return k.Interface()
+	}
+	return nil
+}
+
 func (c *mapCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 	l := v.Len()
 	if uint64(l) > uint64(c.maxlen) {
-		return errors.LengthError{uint64(l), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(l), Max: uint64(c.origMax), Offset: -1}
 	}
 
 	if err := e.EncodeUnsignedInt(uint32(l)); err != nil {
 		return err
 	}
 
+	if enc, ok := e.(*encoder); ok && enc.sortedMaps {
+		return c.encodeSorted(enc, v)
+	}
+
 	iter := v.MapRange()
 	for iter.Next() {
 		if err := c.keyCodec.Encode(e, iter.Key()); err != nil {
-			return err
+			return errors.WithKeyError(err, -1, keyInterface(iter.Key()))
 		}
 
 		if err := c.valueCodec.Encode(e, iter.Value()); err != nil {
-			return err
+			return errors.WithKeyError(err, -1, keyInterface(iter.Key()))
 		}
 	}
 	return nil
 }
 
 func (c *mapCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	l, err := d.DecodeUnsignedInt()
 	switch {
 	case err != nil:
 		return err
 	case l > uint32(c.maxlen):
-		return errors.LengthError{uint64(l), uint64(c.origMax)}
+		return decodeLengthError(d, uint64(l), uint64(c.origMax))
+	}
+
+	if err := decodeElements(d, l, c.kt.Size()+c.vt.Size()); err != nil {
+		return err
+	}
+
+	// Don't trust the length for preallocation beyond allocChunk bytes
+	hint := int(l)
+	if es := c.kt.Size() + c.vt.Size(); es != 0 && uintptr(hint) > allocChunk/es {
+		hint = int(allocChunk / es)
 	}
 
-	v.Set(reflect.MakeMapWithSize(c.t, int(l)))
+	v.Set(reflect.MakeMapWithSize(c.t, hint))
 	for i := uint32(0); i < l; i++ {
 		kp := reflect.New(c.kt)
 		vp := reflect.New(c.vt)
 
 		k, vv := kp.Elem(), vp.Elem()
 
+		off := d.Offset()
 		if err := c.keyCodec.Decode(d, k); err != nil {
-			return err
+			// We don't know the key, so identify the entry by its index
+			return errors.WithIndexError(unexpectedEOF(err), off, int(i))
 		}
 
+		voff := d.Offset()
 		if err := c.valueCodec.Decode(d, vv); err != nil {
-			return err
+			return errors.WithKeyError(unexpectedEOF(err), voff, keyInterface(k))
 		}
 
 		v.SetMapIndex(k, vv)
 	}
 	return nil
 }
+
+// encodeSorted encodes the entries of the map v in ascending order of their
+// encoded keys. Keys which are integers or strings (and are encoded by the built
+// in codecs) are compared directly; anything else is encoded into a buffer so
+// that the encoded bytes may be compared
+func (c *mapCodec) encodeSorted(e *encoder, v reflect.Value) error {
+	keys := v.MapKeys()
+
+	switch {
+	case c.keyCodec == boolCodecI:
+		sort.Slice(keys, func(i, j int) bool {
+			return !keys[i].Bool() && keys[j].Bool()
+		})
+
+	case c.keyCodec == int8CodecI || c.keyCodec == int16CodecI ||
+		c.keyCodec == int32CodecI || c.keyCodec == hyperCodecI:
+		// Two's complement representation means that signed integers sort by
+		// their unsigned bit patterns (and sign extension preserves this)
+		sort.Slice(keys, func(i, j int) bool {
+			return uint64(keys[i].Int()) < uint64(keys[j].Int())
+		})
+
+	case c.keyCodec == uint8CodecI || c.keyCodec == uint16CodecI ||
+		c.keyCodec == uint32CodecI || c.keyCodec == uhyperCodecI:
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Uint() < keys[j].Uint()
+		})
+
+	case isVarString(c.keyCodec):
+		// The length prefix is encoded first
+		sort.Slice(keys, func(i, j int) bool {
+			ki, kj := keys[i].String(), keys[j].String()
+			if len(ki) != len(kj) {
+				return len(ki) < len(kj)
+			}
+			return ki < kj
+		})
+
+	default:
+		return c.encodeSortedByBytes(e, v, keys)
+	}
+
+	for _, k := range keys {
+		if err := c.keyCodec.Encode(e, k); err != nil {
+			return errors.WithKeyError(err, -1, keyInterface(k))
+		}
+
+		if err := c.valueCodec.Encode(e, v.MapIndex(k)); err != nil {
+			return errors.WithKeyError(err, -1, keyInterface(k))
+		}
+	}
+	return nil
+}
+
+func isVarString(c xCodec) bool {
+	_, ok := c.(*varStringCodec)
+	return ok
+}
+
+// encodeSortedByBytes handles the general case of encodeSorted, by encoding every
+// key and sorting the results
+func (c *mapCodec) encodeSortedByBytes(e *encoder, v reflect.Value, keys []reflect.Value) error {
+	ke := appendEncoderPool.Get().(*appendEncoder)
+	defer ke.release()
+	ke.reset(e.cr, nil)
+	ke.sortedMaps = true
+
+	type encodedKey struct {
+		k          reflect.Value
+		start, end int
+	}
+
+	encoded := make([]encodedKey, len(keys))
+	for i, k := range keys {
+		start := len(ke.b.b)
+		if err := c.keyCodec.Encode(&ke.encoder, k); err != nil {
+			return errors.WithKeyError(err, -1, keyInterface(k))
+		}
+		encoded[i] = encodedKey{k, start, len(ke.b.b)}
+	}
+
+	buf := ke.b.b
+	sort.Slice(encoded, func(i, j int) bool {
+		ki, kj := encoded[i], encoded[j]
+		return bytes.Compare(buf[ki.start:ki.end], buf[kj.start:kj.end]) < 0
+	})
+
+	for _, ek := range encoded {
+		if _, err := e.w.Write(buf[ek.start:ek.end]); err != nil {
+			return err
+		}
+
+		if err := c.valueCodec.Encode(e, v.MapIndex(ek.k)); err != nil {
+			return errors.WithKeyError(err, -1, keyInterface(ek.k))
+		}
+	}
+	return nil
+}
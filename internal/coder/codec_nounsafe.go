@@ -22,3 +22,9 @@ func toXCodec(c xdrinterfaces.Codec, t reflect.Type) xCodec {
 func toOriginalCodec(x xCodec) xdrinterfaces.Codec {
 	return x
 }
+
+// bytesToString converts b to a string. (In !nounsafe builds, this avoids
+// copying b)
+func bytesToString(b []byte) string {
+	return string(b)
+}
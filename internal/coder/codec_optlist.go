@@ -0,0 +1,115 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"reflect"
+
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+	"go.e43.eu/xdr/internal/tags"
+)
+
+// optListCodec handles slices tagged `optlist`, which are encoded in the XDR
+// optional-data linked list form, i.e. as the equivalent of
+//
+//     struct entry { T value; entry *next; };
+//     entry *list;
+//
+// Each element is preceded by TRUE, and the list is terminated by FALSE
+type optListCodec struct {
+	elem    xCodec
+	t       reflect.Type
+	maxlen  int
+	size    uintptr
+	origMax uint32
+}
+
+func makeOptListCodec(cr *Coder, t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
+	maxlen := tag.OnlyValue()
+
+	// Cap lengths at maxInt
+	origMax := maxlen
+	if uint64(maxlen) > uint64(maxInt) {
+		// Do two step assignment to prevent the compiler from being too smart
+		// and complaining at us on builds where this code is unreachable
+		i := maxInt
+		maxlen = uint32(i)
+	}
+
+	return &optListCodec{
+		elem:    cr.getCodec(t.Elem(), tag.Next()),
+		t:       t,
+		maxlen:  int(maxlen),
+		size:    t.Elem().Size(),
+		origMax: origMax,
+	}
+}
+
+func (c *optListCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	l := v.Len()
+	if uint64(l) > uint64(c.maxlen) {
+		return errors.LengthError{Actual: uint64(l), Max: uint64(c.origMax), Offset: -1}
+	}
+
+	for i := 0; i < l; i++ {
+		if err := e.EncodeBool(true); err != nil {
+			return err
+		}
+
+		if err := c.elem.Encode(e, v.Index(i)); err != nil {
+			return errors.WithIndexError(err, -1, i)
+		}
+	}
+	return e.EncodeBool(false)
+}
+
+func (c *optListCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
+	// The length is not known up front, so we grow the slice as elements arrive
+	var s reflect.Value
+	for i := 0; ; i++ {
+		more, err := d.DecodeBool()
+		switch {
+		case err != nil && i != 0:
+			return unexpectedEOF(err)
+		case err != nil:
+			return err
+		case !more && i == 0:
+			v.Set(reflect.Zero(c.t))
+			return nil
+		case !more:
+			v.Set(s.Slice(0, i))
+			return nil
+		case i == c.maxlen:
+			return decodeLengthError(d, uint64(i)+1, uint64(c.origMax))
+		}
+
+		if err := decodeElements(d, 1, c.size); err != nil {
+			return err
+		}
+
+		if !s.IsValid() || i == s.Len() {
+			s = growOptList(c.t, s)
+		}
+
+		off := d.Offset()
+		if err := c.elem.Decode(d, s.Index(i)); err != nil {
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
+		}
+	}
+}
+
+// growOptList returns a new slice (of type t) containing the contents of s, with
+// its length doubled (or of length 1 if s is not yet valid)
+func growOptList(t reflect.Type, s reflect.Value) reflect.Value {
+	if !s.IsValid() {
+		return reflect.MakeSlice(t, 1, 1)
+	}
+	return growSlice(t, s, maxInt)
+}
@@ -7,6 +7,9 @@ import (
 	"reflect"
 
 	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+	"go.e43.eu/xdr/internal/quadruple"
+	"go.e43.eu/xdr/internal/tags"
 )
 
 // boolCodec handles booleans
@@ -25,15 +28,22 @@ func (_ boolCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
 }
 
 // [u]intCodec handle basic ([u]int8-[u]int32) integers
-type intCodec struct{}
-type uintCodec struct{}
+//
+// When decoding into a type narrower than 32 bits, values which are out of range
+// cause an OverflowError, unless wrap is set (by the `wrap` tag), in which case
+// they are truncated. (Encoding can never overflow)
+type intCodec struct{ wrap bool }
+type uintCodec struct{ wrap bool }
 
 func (_ intCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 	return e.EncodeInt(int32(v.Int()))
 }
 
-func (_ intCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+func (c intCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
 	i, e := d.DecodeInt()
+	if e == nil && !c.wrap && v.OverflowInt(int64(i)) {
+		return errors.OverflowError{Value: int64(i), Type: v.Kind().String()}
+	}
 	v.SetInt(int64(i))
 	return e
 }
@@ -44,6 +54,9 @@ func (uc uintCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 
 func (uc uintCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
 	i, e := d.DecodeUnsignedInt()
+	if e == nil && !uc.wrap && v.OverflowUint(uint64(i)) {
+		return errors.OverflowError{Value: uint64(i), Type: v.Kind().String()}
+	}
 	v.SetUint(uint64(i))
 	return e
 }
@@ -63,8 +76,32 @@ var (
 	uint8CodecI  xCodec = uint8Codec{}
 	uint16CodecI xCodec = uint16Codec{}
 	uint32CodecI xCodec = uint32Codec{}
+
+	// Variants which truncate out of range values on decode
+	int8WrapCodecI   xCodec = int8Codec{intCodec{wrap: true}}
+	int16WrapCodecI  xCodec = int16Codec{intCodec{wrap: true}}
+	uint8WrapCodecI  xCodec = uint8Codec{uintCodec{wrap: true}}
+	uint16WrapCodecI xCodec = uint16Codec{uintCodec{wrap: true}}
 )
 
+// makeNarrowIntCodec constructs a codec for a tagged [u]int8 or [u]int16
+func makeNarrowIntCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
+	if tag.Kind() != tags.Wrap || !tag.Next().Empty() {
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
+	}
+
+	switch t.Kind() {
+	case reflect.Int8:
+		return int8WrapCodecI
+	case reflect.Int16:
+		return int16WrapCodecI
+	case reflect.Uint8:
+		return uint8WrapCodecI
+	default: // reflect.Uint16
+		return uint16WrapCodecI
+	}
+}
+
 // [u]hyperCodec handles hyper ([u]int64) integers
 type hyperCodec struct{}
 type uhyperCodec struct{}
@@ -176,3 +213,32 @@ func (_ complex128Codec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error
 	v.SetComplex(complex(re, im))
 	return nil
 }
+
+// quadrupleCodec handles Quadruples
+type quadrupleCodec struct{}
+
+var (
+	quadrupleType          = reflect.TypeOf(quadruple.Quadruple{})
+	quadrupleCodecI xCodec = quadrupleCodec{}
+)
+
+func (_ quadrupleCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	q := v.Interface().(quadruple.Quadruple)
+	if err := e.EncodeUnsignedHyper(q.Hi); err != nil {
+		return err
+	}
+	return e.EncodeUnsignedHyper(q.Lo)
+}
+
+func (_ quadrupleCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	hi, err := d.DecodeUnsignedHyper()
+	if err != nil {
+		return err
+	}
+	lo, err := d.DecodeUnsignedHyper()
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(quadruple.Quadruple{Hi: hi, Lo: lo}))
+	return nil
+}
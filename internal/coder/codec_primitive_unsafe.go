@@ -6,9 +6,12 @@
 package coder
 
 import (
+	"math"
 	"unsafe"
 
 	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+	"go.e43.eu/xdr/internal/quadruple"
 )
 
 func (c boolCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
@@ -27,6 +30,9 @@ func (c int8Codec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error
 
 func (c int8Codec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
 	i, err := d.DecodeInt()
+	if err == nil && !c.wrap && (i < math.MinInt8 || i > math.MaxInt8) {
+		return errors.OverflowError{Value: int64(i), Type: "int8"}
+	}
 	*(*int8)(p) = int8(i)
 	return err
 }
@@ -37,6 +43,9 @@ func (c int16Codec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) erro
 
 func (c int16Codec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
 	i, err := d.DecodeInt()
+	if err == nil && !c.wrap && (i < math.MinInt16 || i > math.MaxInt16) {
+		return errors.OverflowError{Value: int64(i), Type: "int16"}
+	}
 	*(*int16)(p) = int16(i)
 	return err
 }
@@ -57,6 +66,9 @@ func (c uint8Codec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) erro
 
 func (c uint8Codec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
 	i, err := d.DecodeUnsignedInt()
+	if err == nil && !c.wrap && i > math.MaxUint8 {
+		return errors.OverflowError{Value: uint64(i), Type: "uint8"}
+	}
 	*(*uint8)(p) = uint8(i)
 	return err
 }
@@ -67,6 +79,9 @@ func (c uint16Codec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) err
 
 func (c uint16Codec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
 	i, err := d.DecodeUnsignedInt()
+	if err == nil && !c.wrap && i > math.MaxUint16 {
+		return errors.OverflowError{Value: uint64(i), Type: "uint16"}
+	}
 	*(*uint16)(p) = uint16(i)
 	return err
 }
@@ -162,3 +177,58 @@ func (_ complex128Codec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer)
 	*(*complex128)(p) = complex(re, im)
 	return nil
 }
+
+func (c goIntCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	return c.encode(e, int64(*(*int)(p)))
+}
+
+func (c goIntCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	i, err := c.decode(d)
+	if err != nil {
+		return err
+	}
+	*(*int)(p) = i
+	return nil
+}
+
+func (c goUintCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	if c.uintptr {
+		return c.encode(e, uint64(*(*uintptr)(p)))
+	}
+	return c.encode(e, uint64(*(*uint)(p)))
+}
+
+func (c goUintCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	u, err := c.decode(d)
+	if err != nil {
+		return err
+	}
+
+	if c.uintptr {
+		*(*uintptr)(p) = uintptr(u)
+	} else {
+		*(*uint)(p) = uint(u)
+	}
+	return nil
+}
+
+func (_ quadrupleCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	q := (*quadruple.Quadruple)(p)
+	if err := e.EncodeUnsignedHyper(q.Hi); err != nil {
+		return err
+	}
+	return e.EncodeUnsignedHyper(q.Lo)
+}
+
+func (_ quadrupleCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	hi, err := d.DecodeUnsignedHyper()
+	if err != nil {
+		return err
+	}
+	lo, err := d.DecodeUnsignedHyper()
+	if err != nil {
+		return err
+	}
+	*(*quadruple.Quadruple)(p) = quadruple.Quadruple{Hi: hi, Lo: lo}
+	return nil
+}
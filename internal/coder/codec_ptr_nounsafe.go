@@ -20,6 +20,9 @@ func (pc *ptrCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 }
 
 func (pc *ptrCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeAllocate(d, pc.elemt.Size()); err != nil {
+		return err
+	}
 	v.Set(reflect.New(pc.elemt))
 	return pc.elem.Decode(d, v.Elem())
 }
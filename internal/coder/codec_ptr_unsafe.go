@@ -32,7 +32,13 @@ func (c *optCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error
 	} else if notNil {
 		return c.elem.decodeUnsafe(d, p)
 	}
-	*(*uintptr)(p) = 0
+
+	if c.nilp.Kind() == reflect.Interface {
+		// Interfaces are two words
+		reflect.NewAt(c.nilp.Type(), p).Elem().Set(c.nilp)
+	} else {
+		*(*uintptr)(p) = 0
+	}
 	return nil
 }
 
@@ -44,6 +50,9 @@ func (c *ptrCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 }
 
 func (c *ptrCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeAllocate(d, c.elemt.Size()); err != nil {
+		return err
+	}
 	v.Set(reflect.New(c.elemt))
 	return c.elem.decodeUnsafe(d, unsafe.Pointer(v.Pointer()))
 }
@@ -56,6 +65,9 @@ func (c *ptrCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error
 }
 
 func (c *ptrCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	if err := decodeAllocate(d, c.elemt.Size()); err != nil {
+		return err
+	}
 	v := unsafe.Pointer(reflect.New(c.elemt).Pointer())
 	*(*unsafe.Pointer)(p) = v
 	return c.elem.decodeUnsafe(d, v)
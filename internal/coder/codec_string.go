@@ -48,14 +48,14 @@ func makeStringCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec {
 		len = ^uint32(0)
 
 	default:
-		return &errorCodec{errors.InvalidTagForTypeError{t, tag}}
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
 	}
 
 	origMax := len
 	if uint64(len) > uint64(maxInt) {
 		if fixed {
 			// This can never work; reducing the maximum would be erroneous
-			return &errorCodec{errors.LengthError{uint64(len), uint64(len)}}
+			return &errorCodec{errors.LengthError{Actual: uint64(len), Max: uint64(len), Offset: -1}}
 		}
 
 		// Do two step assignment to prevent the compiler from being too smart
@@ -97,7 +97,7 @@ func (c *varStringCodec) encode(e xdrinterfaces.Encoder, s string) error {
 	if uint64(len(s)) <= uint64(c.maxlen) {
 		return e.EncodeString(s)
 	} else {
-		return errors.LengthError{uint64(len(s)), uint64(c.origMax)}
+		return errors.LengthError{Actual: uint64(len(s)), Max: uint64(c.origMax), Offset: -1}
 	}
 }
 
@@ -5,7 +5,10 @@ package coder
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
+	"sync"
 
 	xdrinterfaces "go.e43.eu/xdr/interfaces"
 	"go.e43.eu/xdr/internal/errors"
@@ -15,42 +18,223 @@ import (
 type structCodec struct {
 	name   string
 	fields []field
+
+	// Fixed encoded size of the struct (or -1 if variable), and whether it
+	// contains padding; computed lazily
+	sizeOnce sync.Once
+	size     int
+	padded   bool
 }
 
 var _ xCodec = &structCodec{}
 
-type switchKind byte
-
-const (
-	switchKindBool switchKind = iota
-	switchKindInt
-	switchKindUint
-)
-
 type unionCodec struct {
 	name        string
 	switchField field
 	bodyFields  []field
-	cases       map[uint32]int
 	defaultCase int
-	switchKind  switchKind
+
+	// Maps switch values to indexes into bodyFields. Switch values are keyed as
+	// 64-bit integers; signed values are sign extended
+	cases      map[uint64]int
+	switchKind reflect.Kind
+
+	// The codec of the switch type, if it is a registered enum (used to name the
+	// switch value in errors)
+	switchEnum *enumCodec
+
+	// If set, the switch is inferred from the populated arm when encoding. armKeys
+	// maps the index of each (non-default) arm to the first of its case keys
+	infer   bool
+	t       reflect.Type
+	armKeys map[int]uint64
 }
 
 var _ xCodec = &unionCodec{}
 
+// switchCodec handles union switches of the wide integer types (int, int64, uint,
+// uint64 and uintptr), which would otherwise be encoded as hypers. XDR discriminants
+// are always an int or unsigned int; values which cannot be represented as such cause
+// an OverflowError
+type switchCodec struct {
+	t      reflect.Type
+	signed bool
+}
+
+var _ xCodec = &switchCodec{}
+
+// structField is a field of a struct, which may have been promoted from an
+// embedded struct
+type structField struct {
+	reflect.StructField
+
+	// Embedded pointers which must be traversed to reach this field
+	embed []embedStep
+}
+
+// embedStep describes an embedded pointer to a struct which must be traversed in
+// order to reach a promoted field
+type embedStep struct {
+	index    []int        // Index of the pointer relative to the previous struct
+	offset   uintptr      // Offset of the pointer relative to the previous struct
+	t        reflect.Type // The struct type pointed to
+	exported bool         // Whether the pointer field is exported (and so settable)
+}
+
+// structFields returns the fields of t in declaration order, with the fields of
+// embedded structs promoted inline
+//
+// An embedded struct (or pointer to struct) is promoted unless it has an XDR tag,
+// is a union, or has its own encoding (a registered codec or Marshaler). In those
+// cases it is encoded like any other field
+func (cr *Coder) structFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	err := cr.appendStructFields(&fields, t, nil, 0, nil, map[reflect.Type]bool{t: true})
+	return fields, err
+}
+
+func (cr *Coder) appendStructFields(
+	fields *[]structField,
+	t reflect.Type,
+	index []int,
+	offset uintptr,
+	embed []embedStep,
+	visiting map[reflect.Type]bool,
+) error {
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		f.Index = append(append([]int(nil), index...), i)
+		f.Offset += offset
+
+		if !cr.isPromoted(f) {
+			*fields = append(*fields, structField{f, embed})
+			continue
+		}
+
+		et := f.Type
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+
+		if visiting[et] {
+			return fmt.Errorf("Embedded struct '%s' of '%s' embeds itself", f.Name, t)
+		}
+		visiting[et] = true
+
+		var err error
+		if f.Type.Kind() == reflect.Ptr {
+			step := embedStep{
+				index:    f.Index,
+				offset:   f.Offset,
+				t:        et,
+				exported: f.PkgPath == "",
+			}
+			err = cr.appendStructFields(fields, et, nil, 0,
+				append(append([]embedStep(nil), embed...), step), visiting)
+		} else {
+			err = cr.appendStructFields(fields, et, f.Index, f.Offset, embed, visiting)
+		}
+		if err != nil {
+			return err
+		}
+		delete(visiting, et)
+	}
+	return nil
+}
+
+// isPromoted returns whether f is an embedded struct whose fields should be
+// promoted into the enclosing struct
+func (cr *Coder) isPromoted(f reflect.StructField) bool {
+	if !f.Anonymous {
+		return false
+	}
+
+	if _, tagged := f.Tag.Lookup("xdr"); tagged {
+		return false
+	}
+
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t.Implements(marshalerType) || isUnionType(t) {
+		return false
+	}
+
+	if c, ok := cr.knownCodecs.Load(xType{t, ""}); ok {
+		switch toOriginalCodec(c.(xCodec)).(type) {
+		case *structCodec, *deferredCodec:
+			// Built by us
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isUnionType returns whether the struct type t is a union
+func isUnionType(t reflect.Type) bool {
+	for i, n := 0, t.NumField(); i < n; i++ {
+		tag := strings.TrimSpace(t.Field(i).Tag.Get("xdr"))
+		if tag != "-" {
+			return strings.HasPrefix(tag, "union:")
+		}
+	}
+	return false
+}
+
+// value returns the value of the field within the struct p. Nil embedded
+// pointers are allocated if alloc is set, otherwise ErrNilPointer is returned
+func (f *field) value(p reflect.Value, alloc bool) (reflect.Value, error) {
+	for i := range f.embed {
+		s := &f.embed[i]
+		p = p.FieldByIndex(s.index)
+		if p.IsNil() {
+			if err := s.checkAlloc(alloc); err != nil {
+				return reflect.Value{}, err
+			}
+			p.Set(reflect.New(s.t))
+		}
+		p = p.Elem()
+	}
+
+	if len(f.index) == 1 {
+		return p.Field(f.index[0]), nil
+	}
+	return p.FieldByIndex(f.index), nil
+}
+
+// checkAlloc returns an error if the (nil) embedded pointer may not be allocated
+func (s *embedStep) checkAlloc(alloc bool) error {
+	switch {
+	case !alloc:
+		return errors.ErrNilPointer
+	case !s.exported:
+		// We could do this in !nounsafe builds, but reflect will not let us
+		return fmt.Errorf("xdr: Cannot allocate embedded pointer to unexported type %s", s.t)
+	}
+	return nil
+}
+
 func makeStructCodec(cr *Coder, t reflect.Type) xdrinterfaces.Codec {
 	var (
-		f   reflect.StructField
+		f   structField
 		tag tags.XDRTag
 		err error
 	)
 
+	fields, err := cr.structFields(t)
+	if err != nil {
+		return &errorCodec{err}
+	}
+
 	// Iterate until we figure out if we're a union or not
 	isUnion := tags.MaybeInUnion
-	i, fieldCount := 0, t.NumField()
+	i, fieldCount := 0, len(fields)
 	for ; i < fieldCount && isUnion == tags.MaybeInUnion; i++ {
-		f = t.Field(i)
-		tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion)
+		f = fields[i]
+		tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion, cr.lookupConstant)
 		if err != nil {
 			return &errorCodec{fmt.Errorf("Parsing tag of field '%s' of '%s': %v",
 				f.Name, t, err)}
@@ -80,8 +264,8 @@ func makeStructCodec(cr *Coder, t reflect.Type) xdrinterfaces.Codec {
 
 		c.fields = append(c.fields, makeField(cr, f, tag))
 		for ; i < fieldCount; i++ {
-			f = t.Field(i)
-			tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion)
+			f = fields[i]
+			tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion, cr.lookupConstant)
 			if err != nil {
 				return &errorCodec{fmt.Errorf("Parsing tag of field '%s' of '%s': %v",
 					f.Name, t, err)}
@@ -99,39 +283,27 @@ func makeStructCodec(cr *Coder, t reflect.Type) xdrinterfaces.Codec {
 	case tags.InUnion:
 		// We're acually a union, and f is our switch
 		// Every following field is going to be prefixed by the xt_unioncases or xt_uniondefault tag
-		if tag.Kind() != tags.UnionSwitch {
+		if tag.Kind() != tags.UnionSwitch && tag.Kind() != tags.UnionSwitchInfer {
 			// Shouldn't happen
 			panic("First element of union not switch")
 		}
 
-		var switchKind switchKind
-		switch f.Type.Kind() {
-		case reflect.Int32:
-			switchKind = switchKindInt
-
-		case reflect.Uint32:
-			switchKind = switchKindUint
-
-		case reflect.Bool:
-			switchKind = switchKindBool
-
-		default:
-			// Shouldn't happen - tag parsing should have validated legality
-			panic("Switch field of union not valid (must be int32, uint32 or bool)")
-		}
-
 		c := &unionCodec{
 			name:        t.Name(),
-			switchField: makeField(cr, f, tag.Next()),
+			switchField: makeSwitchField(cr, f, tag.Next()),
 			bodyFields:  make([]field, fieldCount),
-			cases:       make(map[uint32]int, fieldCount-1),
+			cases:       make(map[uint64]int, fieldCount-1),
 			defaultCase: -1,
-			switchKind:  switchKind,
+			switchKind:  f.Type.Kind(),
+			switchEnum:  cr.lookupEnum(f.Type),
+			infer:       tag.Kind() == tags.UnionSwitchInfer,
+			t:           t,
+			armKeys:     make(map[int]uint64),
 		}
 
 		for ; i < fieldCount; i++ {
-			f = t.Field(i)
-			tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion)
+			f = fields[i]
+			tag, err = tags.ParseStructTag(f.Type, f.Tag, &isUnion, cr.lookupConstant)
 			if err != nil {
 				return &errorCodec{fmt.Errorf("Parsing tag of field '%s' of '%s': %v",
 					f.Name, t, err)}
@@ -145,12 +317,19 @@ func makeStructCodec(cr *Coder, t reflect.Type) xdrinterfaces.Codec {
 
 			switch tag.Kind() {
 			case tags.UnionCases:
-				for j, e := tag.ValueRange(); j < e; j++ {
-					v := tag.Value(j)
-					if _, ok := c.cases[v]; ok {
-						return &errorCodec{fmt.Errorf("Union value 0x%08x of %s duplicated", v, t)}
+				for _, label := range tag.Cases() {
+					k, ok := c.caseKey(label)
+					if !ok {
+						return &errorCodec{fmt.Errorf("Union value %v of field '%s' of %s out of range for switch type %s",
+							label, f.Name, t, c.switchField.t)}
+					}
+					if _, ok := c.cases[k]; ok {
+						return &errorCodec{fmt.Errorf("Union value %v of %s duplicated", c.caseValue(k), t)}
 					}
-					c.cases[v] = i
+					if _, ok := c.armKeys[i]; !ok {
+						c.armKeys[i] = k
+					}
+					c.cases[k] = i
 				}
 
 			case tags.UnionDefault:
@@ -168,35 +347,225 @@ func makeStructCodec(cr *Coder, t reflect.Type) xdrinterfaces.Codec {
 	}
 }
 
+// makeSwitchField constructs the field for the switch of a union. Switches of the
+// wide integer types are encoded as 32-bit values, unless they have a custom codec
+func makeSwitchField(cr *Coder, f structField, tag tags.XDRTag) field {
+	sf := makeField(cr, f, tag)
+
+	c := sf.codec
+	if dc, ok := c.(*deferredCodec); ok {
+		real := dc.real.Load()
+		if real == nil {
+			dc.wg.Wait()
+			real = dc.real.Load()
+		}
+		c = real.(xCodec)
+	}
+
+	switch toOriginalCodec(c).(type) {
+	case hyperCodec, goIntCodec:
+		sf.codec = toXCodec(&switchCodec{f.Type, true}, f.Type)
+	case uhyperCodec, goUintCodec:
+		sf.codec = toXCodec(&switchCodec{f.Type, false}, f.Type)
+	}
+	return sf
+}
+
+func (c *switchCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
+	if c.signed {
+		i := v.Int()
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return errors.OverflowError{Value: i, Type: "int"}
+		}
+		return e.EncodeInt(int32(i))
+	}
+
+	u := v.Uint()
+	if u > math.MaxUint32 {
+		return errors.OverflowError{Value: u, Type: "unsigned int"}
+	}
+	return e.EncodeUnsignedInt(uint32(u))
+}
+
+func (c *switchCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if c.signed {
+		i, err := d.DecodeInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(int64(i)) {
+			return errors.OverflowError{Value: int64(i), Type: c.t.String()}
+		}
+		v.SetInt(int64(i))
+		return nil
+	}
+
+	u, err := d.DecodeUnsignedInt()
+	if err != nil {
+		return err
+	}
+	if v.OverflowUint(uint64(u)) {
+		return errors.OverflowError{Value: uint64(u), Type: c.t.String()}
+	}
+	v.SetUint(uint64(u))
+	return nil
+}
+
+// armError wraps err with the path to the arm field, selected by the switch
+// value with key swVal
+func (c *unionCodec) armError(err error, offset int64, field string, swVal uint64) error {
+	cv := c.caseValue(swVal)
+
+	var name string
+	if c.switchEnum != nil {
+		name, _ = c.switchEnum.name(reflect.ValueOf(cv))
+	}
+	return errors.WithUnionArmError(err, offset, c.name, field, cv, name)
+}
+
+// caseKey converts a case label into a key into our case map, returning false if
+// the label is out of range for the switch type (or for the int or unsigned int
+// as which it is encoded)
+func (c *unionCodec) caseKey(label int64) (uint64, bool) {
+	v := reflect.New(c.switchField.t).Elem()
+	switch c.switchKind {
+	case reflect.Bool:
+		return uint64(label), label == 0 || label == 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		inRange := label >= math.MinInt32 && label <= math.MaxInt32
+		return uint64(label), inRange && !v.OverflowInt(label)
+	default:
+		inRange := label >= 0 && label <= math.MaxUint32
+		return uint64(label), inRange && !v.OverflowUint(uint64(label))
+	}
+}
+
+// switchKey returns the key into our case map for the switch value swv
+func (c *unionCodec) switchKey(swv reflect.Value) uint64 {
+	switch c.switchKind {
+	case reflect.Bool:
+		if swv.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(swv.Int())
+	default:
+		return swv.Uint()
+	}
+}
+
+// caseValue returns the switch value with key swVal as a value of the switch type
+func (c *unionCodec) caseValue(swVal uint64) interface{} {
+	v := reflect.New(c.switchField.t).Elem()
+	switch c.switchKind {
+	case reflect.Bool:
+		v.SetBool(swVal != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(swVal))
+	default:
+		v.SetUint(swVal)
+	}
+	return v.Interface()
+}
+
 func (c *structCodec) encodeReflect(e xdrinterfaces.Encoder, v reflect.Value) error {
 	for _, f := range c.fields {
 		_, err := f.encode(e, v)
 		if err != nil {
-			return errors.WithFieldError(err, c.name, f.name)
+			return errors.WithFieldError(err, -1, c.name, f.name)
 		}
 	}
 	return nil
 }
 
-func (c *unionCodec) encodeReflect(e xdrinterfaces.Encoder, v reflect.Value) (err error) {
-	swv, err := c.switchField.encode(e, v)
+// isPopulated returns whether v (the value of a union arm) is populated, i.e. is
+// a non-nil pointer, interface, map or slice. Other arms are never populated
+func isPopulated(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return !v.IsNil()
+	default:
+		return false
+	}
+}
+
+// encodeInferred encodes a union with an inferred switch
+//
+// If an arm is populated, and the switch does not select it, then the switch is
+// replaced by the first case of that arm; unless the switch was not the zero value,
+// (or the arm is the default) in which case they conflict
+func (c *unionCodec) encodeInferred(e xdrinterfaces.Encoder, v reflect.Value) error {
+	populated := -1
+	for i := range c.bodyFields {
+		f := &c.bodyFields[i]
+		if f.codec == nil {
+			// Switch or skipped field
+			continue
+		}
+
+		fv, err := f.value(v, false)
+		if err != nil || !isPopulated(fv) {
+			continue
+		}
+
+		if populated != -1 {
+			err := errors.ErrUnionArmMismatch
+			return errors.WithFieldError(err, -1, c.name, f.name)
+		}
+		populated = i
+	}
+
+	swv, err := c.switchField.value(v, false)
 	if err != nil {
-		err = errors.WithFieldError(err, c.name, c.switchField.name, "union:switch")
-		return
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
 	}
 
-	var swVal uint32
-	switch c.switchKind {
-	case switchKindBool:
-		if swv.Bool() {
-			swVal = 1
+	swVal := c.switchKey(swv)
+	caseField, exists := c.cases[swVal]
+	if !exists {
+		caseField = c.defaultCase
+	}
+
+	if populated != -1 && caseField != populated {
+		key, hasKey := c.armKeys[populated]
+		if !hasKey || swVal != 0 {
+			err := errors.ErrUnionArmMismatch
+			return errors.WithFieldError(err, -1, c.name, c.bodyFields[populated].name)
 		}
-	case switchKindUint:
-		swVal = uint32(swv.Uint())
-	default: //switchKindInt
-		swVal = uint32(swv.Int())
+
+		swVal, caseField = key, populated
+		swv = reflect.ValueOf(c.caseValue(key))
+	}
+
+	if err := c.switchField.codec.Encode(e, swv); err != nil {
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
+	}
+
+	if caseField == -1 {
+		err := errors.ErrUnionSwitchArmUndefined
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
+	}
+
+	f := &c.bodyFields[caseField]
+	if _, err := f.encode(e, v); err != nil {
+		return c.armError(err, -1, f.name, swVal)
+	}
+	return nil
+}
+
+func (c *unionCodec) encodeReflect(e xdrinterfaces.Encoder, v reflect.Value) (err error) {
+	if c.infer {
+		return c.encodeInferred(e, v)
+	}
+
+	swv, err := c.switchField.encode(e, v)
+	if err != nil {
+		err = errors.WithFieldError(err, -1, c.name, c.switchField.name)
+		return
 	}
 
+	swVal := c.switchKey(swv)
 	caseField, exists := c.cases[swVal]
 	if !exists {
 		caseField = c.defaultCase
@@ -204,13 +573,13 @@ func (c *unionCodec) encodeReflect(e xdrinterfaces.Encoder, v reflect.Value) (er
 
 	if caseField == -1 {
 		err = errors.ErrUnionSwitchArmUndefined
-		return errors.WithFieldError(err, c.name, "?", fmt.Sprintf("union:0x%x", caseField))
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
 	}
 
 	f := c.bodyFields[caseField]
 	_, err = f.encode(e, v)
 	if err != nil {
-		err = errors.WithFieldError(err, c.name, f.name, fmt.Sprintf("union:0x%x", swVal))
+		err = c.armError(err, -1, f.name, swVal)
 	}
 	return
 }
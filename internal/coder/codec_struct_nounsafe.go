@@ -6,7 +6,6 @@
 package coder
 
 import (
-	"fmt"
 	"reflect"
 
 	xdrinterfaces "go.e43.eu/xdr/interfaces"
@@ -15,32 +14,38 @@ import (
 )
 
 type field struct {
-	index int
+	index []int
+	t     reflect.Type
 	codec xCodec
 	name  string
+	embed []embedStep
 }
 
-func makeField(cr *Coder, f reflect.StructField, tag tags.XDRTag) field {
-	if len(f.Index) != 1 {
-		panic("Attempt to make field with index of depth >1")
-	}
-
+func makeField(cr *Coder, f structField, tag tags.XDRTag) field {
 	return field{
-		index: f.Index[0],
+		index: f.Index,
+		t:     f.Type,
 		codec: cr.getCodec(f.Type, tag),
 		name:  f.Name,
+		embed: f.embed,
 	}
 }
 
 func (f *field) encode(e xdrinterfaces.Encoder, p reflect.Value) (reflect.Value, error) {
-	v := p.Field(f.index)
-	err := f.codec.Encode(e, v)
+	v, err := f.value(p, false)
+	if err != nil {
+		return v, err
+	}
+	err = f.codec.Encode(e, v)
 	return v, err
 }
 
 func (f *field) decode(d xdrinterfaces.Decoder, p reflect.Value) (reflect.Value, error) {
-	v := p.Field(f.index)
-	err := f.codec.Decode(d, v)
+	v, err := f.value(p, true)
+	if err != nil {
+		return v, err
+	}
+	err = f.codec.Decode(d, v)
 	return v, err
 }
 
@@ -49,10 +54,16 @@ func (c *structCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 }
 
 func (c *structCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	for _, f := range c.fields {
+		off := d.Offset()
 		_, err := f.decode(d, v)
 		if err != nil {
-			return errors.WithFieldError(err, c.name, f.name)
+			return errors.WithFieldError(err, off, c.name, f.name)
 		}
 	}
 	return nil
@@ -63,24 +74,19 @@ func (c *unionCodec) Encode(e xdrinterfaces.Encoder, v reflect.Value) error {
 }
 
 func (c *unionCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) (err error) {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
+	swOff := d.Offset()
 	swv, err := c.switchField.decode(d, v)
 	if err != nil {
-		err = errors.WithFieldError(err, c.name, c.switchField.name, "union:switch")
+		err = errors.WithFieldError(err, swOff, c.name, c.switchField.name)
 		return
 	}
 
-	var swVal uint32
-	switch c.switchKind {
-	case switchKindBool:
-		if swv.Bool() {
-			swVal = 1
-		}
-	case switchKindUint:
-		swVal = uint32(swv.Uint())
-	default: //switchKindInt
-		swVal = uint32(swv.Int())
-	}
-
+	swVal := c.switchKey(swv)
 	caseField, exists := c.cases[swVal]
 	if !exists {
 		caseField = c.defaultCase
@@ -88,13 +94,14 @@ func (c *unionCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) (err error
 
 	if caseField == -1 {
 		err = errors.ErrUnionSwitchArmUndefined
-		return errors.WithFieldError(err, c.name, "?", fmt.Sprintf("union:0x%x", caseField))
+		return errors.WithFieldError(err, swOff, c.name, c.switchField.name)
 	}
 
 	f := c.bodyFields[caseField]
+	off := d.Offset()
 	_, err = f.decode(d, v)
 	if err != nil {
-		err = errors.WithFieldError(err, c.name, f.name, fmt.Sprintf("union:0x%x", swVal))
+		err = c.armError(err, off, f.name, swVal)
 	}
 	return
 }
@@ -6,7 +6,6 @@
 package coder
 
 import (
-	"fmt"
 	"reflect"
 	"unsafe"
 
@@ -16,48 +15,75 @@ import (
 )
 
 type field struct {
-	index  int
+	index  []int
 	offset uintptr
 	t      reflect.Type
 	codec  xCodec
 	name   string
+	embed  []embedStep
 }
 
-func makeField(cr *Coder, f reflect.StructField, tag tags.XDRTag) field {
-	if len(f.Index) != 1 {
-		panic("Attempt to make field with index of depth >1")
-	}
-
+func makeField(cr *Coder, f structField, tag tags.XDRTag) field {
 	return field{
-		index:  f.Index[0],
+		index:  f.Index,
 		offset: f.Offset,
 		t:      f.Type,
 		codec:  cr.getCodec(f.Type, tag),
 		name:   f.Name,
+		embed:  f.embed,
+	}
+}
+
+// pointer returns a pointer to the field within the struct at pparent. Nil embedded
+// pointers are allocated if alloc is set, otherwise ErrNilPointer is returned
+func (f *field) pointer(pparent unsafe.Pointer, alloc bool) (unsafe.Pointer, error) {
+	for i := range f.embed {
+		s := &f.embed[i]
+		pp := (*unsafe.Pointer)(unsafe.Pointer(uintptr(pparent) + s.offset))
+		if *pp == nil {
+			if err := s.checkAlloc(alloc); err != nil {
+				return nil, err
+			}
+			*pp = unsafe.Pointer(reflect.New(s.t).Pointer())
+		}
+		pparent = *pp
 	}
+	return unsafe.Pointer(uintptr(pparent) + f.offset), nil
 }
 
 func (f *field) encode(e xdrinterfaces.Encoder, p reflect.Value) (reflect.Value, error) {
-	v := p.Field(f.index)
-	err := f.codec.Encode(e, v)
+	v, err := f.value(p, false)
+	if err != nil {
+		return v, err
+	}
+	err = f.codec.Encode(e, v)
 	return v, err
 }
 
 func (f *field) encodeUnsafe(e xdrinterfaces.Encoder, pparent unsafe.Pointer) (unsafe.Pointer, error) {
-	p := unsafe.Pointer(uintptr(pparent) + f.offset)
-	err := f.codec.encodeUnsafe(e, p)
+	p, err := f.pointer(pparent, false)
+	if err != nil {
+		return p, err
+	}
+	err = f.codec.encodeUnsafe(e, p)
 	return p, err
 }
 
 func (f *field) decode(d xdrinterfaces.Decoder, p reflect.Value) (reflect.Value, error) {
-	v := p.Field(f.index)
-	err := f.codec.Decode(d, v)
+	v, err := f.value(p, true)
+	if err != nil {
+		return v, err
+	}
+	err = f.codec.Decode(d, v)
 	return v, err
 }
 
 func (f *field) decodeUnsafe(d xdrinterfaces.Decoder, pparent unsafe.Pointer) (unsafe.Pointer, error) {
-	p := unsafe.Pointer(uintptr(pparent) + f.offset)
-	err := f.codec.decodeUnsafe(d, p)
+	p, err := f.pointer(pparent, true)
+	if err != nil {
+		return p, err
+	}
+	err = f.codec.decodeUnsafe(d, p)
 	return p, err
 }
 
@@ -77,17 +103,23 @@ func (c *structCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) er
 	for _, f := range c.fields {
 		_, err := f.encodeUnsafe(e, p)
 		if err != nil {
-			return errors.WithFieldError(err, c.name, f.name)
+			return errors.WithFieldError(err, -1, c.name, f.name)
 		}
 	}
 	return nil
 }
 
 func (c *structCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	if err := decodeEnter(d); err != nil {
+		return err
+	}
+	defer decodeLeave(d)
+
 	for _, f := range c.fields {
+		off := d.Offset()
 		_, err := f.decodeUnsafe(d, p)
 		if err != nil {
-			return errors.WithFieldError(err, c.name, f.name)
+			return errors.WithFieldError(err, off, c.name, f.name)
 		}
 	}
 	return nil
@@ -105,23 +137,58 @@ func (c *unionCodec) Decode(d xdrinterfaces.Decoder, v reflect.Value) error {
 	return c.decodeUnsafe(d, unsafe.Pointer(v.UnsafeAddr()))
 }
 
+// switchKeyUnsafe returns the key into our case map for the switch value at p
+func (c *unionCodec) switchKeyUnsafe(p unsafe.Pointer) uint64 {
+	switch c.switchKind {
+	case reflect.Bool:
+		if *(*bool)(p) {
+			return 1
+		}
+		return 0
+	case reflect.Int:
+		return uint64(*(*int)(p))
+	case reflect.Int8:
+		return uint64(*(*int8)(p))
+	case reflect.Int16:
+		return uint64(*(*int16)(p))
+	case reflect.Int32:
+		return uint64(*(*int32)(p))
+	case reflect.Int64:
+		return uint64(*(*int64)(p))
+	case reflect.Uint:
+		return uint64(*(*uint)(p))
+	case reflect.Uint8:
+		return uint64(*(*uint8)(p))
+	case reflect.Uint16:
+		return uint64(*(*uint16)(p))
+	case reflect.Uint32:
+		return uint64(*(*uint32)(p))
+	case reflect.Uint64:
+		return *(*uint64)(p)
+	default: // reflect.Uintptr
+		return uint64(*(*uintptr)(p))
+	}
+}
+
+func (c *switchCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
+	return c.Encode(e, reflect.NewAt(c.t, p).Elem())
+}
+
+func (c *switchCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
+	return c.Decode(d, reflect.NewAt(c.t, p).Elem())
+}
+
 func (c *unionCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) error {
-	swp, err := c.switchField.encodeUnsafe(e, p)
-	if err != nil {
-		return errors.WithFieldError(err, c.name, c.switchField.name, "union:switch")
+	if c.infer {
+		return c.encodeInferred(e, reflect.NewAt(c.t, p).Elem())
 	}
 
-	var swVal uint32
-	switch c.switchKind {
-	case switchKindBool:
-		if *(*bool)(swp) {
-			swVal = 1
-		}
-	default: // switchKindInt, switchKindUint
-		// Go permits bitcasts by pointer aliasing
-		swVal = *(*uint32)(swp)
+	swp, err := c.switchField.encodeUnsafe(e, p)
+	if err != nil {
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
 	}
 
+	swVal := c.switchKeyUnsafe(swp)
 	caseField, exists := c.cases[swVal]
 	if !exists {
 		caseField = c.defaultCase
@@ -129,34 +196,30 @@ func (c *unionCodec) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Pointer) err
 
 	if caseField == -1 {
 		err = errors.ErrUnionSwitchArmUndefined
-		return errors.WithFieldError(err, c.name, "?", fmt.Sprintf("union:0x%x", caseField))
+		return errors.WithFieldError(err, -1, c.name, c.switchField.name)
 	}
 
 	f := c.bodyFields[caseField]
 	_, err = f.encodeUnsafe(e, p)
 	if err != nil {
-		return errors.WithFieldError(err, c.name, f.name, fmt.Sprintf("union:0x%x", swVal))
+		return c.armError(err, -1, f.name, swVal)
 	}
 	return nil
 }
 
 func (c *unionCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
-	swp, err := c.switchField.decodeUnsafe(d, p)
-	if err != nil {
-		return errors.WithFieldError(err, c.name, c.switchField.name, "union:switch")
+	if err := decodeEnter(d); err != nil {
+		return err
 	}
+	defer decodeLeave(d)
 
-	var swVal uint32
-	switch c.switchKind {
-	case switchKindBool:
-		if *(*bool)(swp) {
-			swVal = 1
-		}
-	default: // switchKindInt, switchKindUint
-		// Go permits bitcasts by pointer aliasing
-		swVal = *(*uint32)(swp)
+	swOff := d.Offset()
+	swp, err := c.switchField.decodeUnsafe(d, p)
+	if err != nil {
+		return errors.WithFieldError(err, swOff, c.name, c.switchField.name)
 	}
 
+	swVal := c.switchKeyUnsafe(swp)
 	caseField, exists := c.cases[swVal]
 	if !exists {
 		caseField = c.defaultCase
@@ -164,13 +227,14 @@ func (c *unionCodec) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) err
 
 	if caseField == -1 {
 		err = errors.ErrUnionSwitchArmUndefined
-		return errors.WithFieldError(err, c.name, "?", fmt.Sprintf("union:0x%x", caseField))
+		return errors.WithFieldError(err, swOff, c.name, c.switchField.name)
 	}
 
 	f := c.bodyFields[caseField]
+	off := d.Offset()
 	_, err = f.decodeUnsafe(d, p)
 	if err != nil {
-		return errors.WithFieldError(err, c.name, f.name, fmt.Sprintf("union:0x%x", swVal))
+		return c.armError(err, off, f.name, swVal)
 	}
 	return nil
 }
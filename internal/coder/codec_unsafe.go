@@ -60,3 +60,9 @@ func (w *unsafeCodecWrapper) encodeUnsafe(e xdrinterfaces.Encoder, p unsafe.Poin
 func (w *unsafeCodecWrapper) decodeUnsafe(d xdrinterfaces.Decoder, p unsafe.Pointer) error {
 	return w.Decode(d, reflect.NewAt(w.t, p).Elem())
 }
+
+// bytesToString converts b to a string without copying it. b must not be
+// modified afterwards
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
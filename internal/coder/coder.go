@@ -25,6 +25,7 @@ const (
 
 var (
 	marshalerType = reflect.TypeOf((*xdrinterfaces.Marshaler)(nil)).Elem()
+	taggerType    = reflect.TypeOf((*xdrinterfaces.Tagger)(nil)).Elem()
 )
 
 type xType struct {
@@ -35,6 +36,21 @@ type xType struct {
 type Coder struct {
 	knownBaseCodecs sync.Map // map[reflect.Type]xCodec
 	knownCodecs     sync.Map // map[xType]xCodec
+	knownSizes      sync.Map // map[reflect.Type]int
+	knownConstants  sync.Map // map[string]int64
+	knownEnums      sync.Map // map[reflect.Type]*enumCodec
+
+	// Default resource limits for decoders
+	limits xdrinterfaces.Limits
+
+	// Whether decoders are in strict mode by default
+	strict bool
+
+	// Whether encoders sort map entries by default
+	sortedMaps bool
+
+	// Whether decoders permit undeclared enum values by default
+	allowUnknownEnums bool
 }
 
 func NewCoder() *Coder {
@@ -104,6 +120,7 @@ var prohibitedPrimitives = map[reflect.Type]struct{}{
 	reflect.TypeOf(float64(0)):    struct{}{},
 	reflect.TypeOf(complex64(0)):  struct{}{},
 	reflect.TypeOf(complex128(0)): struct{}{},
+	quadrupleType:                 struct{}{},
 }
 
 func (cr *Coder) RegisterCodec(template interface{}, c xdrinterfaces.Codec) {
@@ -126,7 +143,84 @@ func (cr *Coder) RegisterCodecReflect(t reflect.Type, c xdrinterfaces.Codec) {
 	}
 }
 
+func (cr *Coder) RegisterConstants(consts map[string]int64) {
+	for name, val := range consts {
+		existing, found := cr.knownConstants.LoadOrStore(name, val)
+		if found && existing.(int64) != val {
+			panic(fmt.Sprintf("Attempt to register constant '%s' = %d but it is already registered as %d", name, val, existing))
+		}
+	}
+}
+
+// lookupConstant resolves constants used in tags
+func (cr *Coder) lookupConstant(name string) (int64, bool) {
+	v, ok := cr.knownConstants.Load(name)
+	if !ok {
+		return 0, false
+	}
+	return v.(int64), true
+}
+
+func (cr *Coder) SetLimits(l xdrinterfaces.Limits) {
+	cr.limits = l
+}
+
+func (cr *Coder) SetStrict(strict bool) {
+	cr.strict = strict
+}
+
+func (cr *Coder) SetSortedMaps(sorted bool) {
+	cr.sortedMaps = sorted
+}
+
+func (cr *Coder) SetAllowUnknownEnums(allow bool) {
+	cr.allowUnknownEnums = allow
+}
+
+// typeTag returns the default tag declared by t through the Tagger interface, if any.
+// Pointers and interfaces never have a type tag (they would otherwise inherit that
+// of the type they point to)
+func (cr *Coder) typeTag(t reflect.Type) (tags.XDRTag, error) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return nil, nil
+	}
+
+	if !t.Implements(taggerType) {
+		return nil, nil
+	}
+
+	isUnion := tags.NotInUnion
+	s := reflect.Zero(t).Interface().(xdrinterfaces.Tagger).XDRTag()
+	tag, err := tags.ParseTag(t, s, &isUnion, cr.lookupConstant)
+	if err != nil {
+		return nil, err
+	}
+	if tag.Kind() == tags.Skip {
+		return nil, fmt.Errorf("Type tag may not be '-'")
+	}
+	return tag.Trimmed(), nil
+}
+
 func (cr *Coder) getNewCodec(xt xType, tag tags.XDRTag) xCodec {
+	// If the type has its own tag, then combine it with ours and use the codec for
+	// the result (which may be shared with other fields)
+	typeTag, err := cr.typeTag(xt.Type)
+	if err != nil {
+		c := toXCodec(&errorCodec{fmt.Errorf("Parsing XDR tag of type %s: %v", xt.Type, err)}, xt.Type)
+		cr.knownCodecs.Store(xt, c)
+		return c
+	}
+
+	if merged := tag.Merge(typeTag); merged.ByteString() != xt.EncodedTag {
+		c := cr.getCodec(xt.Type, merged)
+		cr.knownCodecs.Store(xt, c)
+		if tag.Empty() {
+			cr.knownBaseCodecs.Store(xt.Type, c)
+		}
+		return c
+	}
+
 	// We create a "deferred codec" in order to handle cycles in the type graph. Note
 	// that we also need to be prepared for the possibility that another goroutine
 	// is constructing a type related to this one or looking this one up simultaneously,
@@ -166,6 +260,12 @@ func (cr *Coder) buildCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec
 		return makeOptCodec(cr, t, tag)
 	}
 
+	// Registered enums are always encoded as an int, so the integer encoding tags
+	// (and any others) cannot apply to them
+	if !tag.Empty() && cr.lookupEnum(t) != nil {
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
+	}
+
 	k := t.Kind()
 
 	// Delegate straight through to types with their own tag handling
@@ -184,14 +284,28 @@ func (cr *Coder) buildCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec
 
 	case reflect.Map:
 		return makeMapCodec(cr, t, tag)
+
+	case reflect.Int, reflect.Uint, reflect.Uintptr:
+		// These admit a tag selecting their encoding
+		if !tag.Empty() {
+			return makeGoIntCodec(t, tag)
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Uint8, reflect.Uint16:
+		// These admit the `wrap` tag
+		if !tag.Empty() {
+			return makeNarrowIntCodec(t, tag)
+		}
 	}
 
 	// None of the remaining types admit any tags
 	if !tag.Empty() {
-		return &errorCodec{errors.InvalidTagForTypeError{t, tag}}
+		return &errorCodec{errors.InvalidTagForTypeError{T: t, Tag: tag}}
 	}
 
 	switch {
+	case t == quadrupleType:
+		return quadrupleCodecI
 	case t.Implements(marshalerType):
 		return &marshalerCodecI
 	}
@@ -223,10 +337,12 @@ func (cr *Coder) buildCodec(t reflect.Type, tag tags.XDRTag) xdrinterfaces.Codec
 		return complex64CodecI
 	case reflect.Complex128:
 		return complex128CodecI
+	case reflect.Int, reflect.Uint, reflect.Uintptr:
+		return makeGoIntCodec(t, tag)
 	case reflect.Struct:
 		return makeStructCodec(cr, t)
 	default:
-		return &errorCodec{errors.InvalidTypeError{t}}
+		return &errorCodec{errors.InvalidTypeError{T: t}}
 	}
 }
 
@@ -246,8 +362,26 @@ func (cr *Coder) NewDecoder(r io.Reader) xdrinterfaces.Decoder {
 
 func (cr *Coder) newDecoder(r io.Reader) *decoder {
 	d := decoderPool.Get().(*decoder)
-	d.r = r
+	d.in = countingReader{r: r}
+	d.r = &d.in
+	d.cr = cr
+	d.strict = cr.strict
+	d.allowUnknownEnums = cr.allowUnknownEnums
+	d.SetLimits(cr.limits)
+	return d
+}
+
+// newSliceDecoder constructs a decoder which reads out of buf, optionally in
+// zero-copy mode
+func (cr *Coder) newSliceDecoder(buf []byte, noCopy bool) *decoder {
+	d := decoderPool.Get().(*decoder)
+	d.src.reset(buf)
+	d.r = &d.src
 	d.cr = cr
+	d.noCopy = noCopy
+	d.strict = cr.strict
+	d.allowUnknownEnums = cr.allowUnknownEnums
+	d.SetLimits(cr.limits)
 	return d
 }
 
@@ -261,11 +395,28 @@ func (cr *Coder) Marshal(o interface{}) ([]byte, error) {
 	return append([]byte(nil), e.b.Bytes()...), err
 }
 
+func (cr *Coder) MarshalAppend(dst []byte, o interface{}) ([]byte, error) {
+	e := appendEncoderPool.Get().(*appendEncoder)
+	defer e.release()
+
+	e.reset(cr, dst)
+	if err := e.Encode(o); err != nil {
+		// Discard anything partially written
+		return e.b.b[0:len(dst)], err
+	}
+	return e.b.b, nil
+}
+
 func (cr *Coder) Unmarshal(buf []byte, op interface{}) error {
-	var r bytes.Reader
-	r.Reset(buf)
-	d := cr.newDecoder(&r)
-	err := d.Decode(op)
+	d := cr.newSliceDecoder(buf, false)
+	err := d.decodeAll(op)
+	d.release()
+	return err
+}
+
+func (cr *Coder) UnmarshalNoCopy(buf []byte, op interface{}) error {
+	d := cr.newSliceDecoder(buf, true)
+	err := d.decodeAll(op)
 	d.release()
 	return err
 }
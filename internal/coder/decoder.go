@@ -13,15 +13,55 @@ import (
 	"go.e43.eu/xdr/internal/errors"
 )
 
+// Lengths read from the stream are untrusted, so for objects larger than allocChunk
+// bytes we do not allocate the full object up front. Instead, storage is grown as
+// data actually arrives, so that a truncated stream cannot cause us to allocate
+// much more memory than it actually contained
+const allocChunk = 64 << 10
+
 var decoderPool = sync.Pool{
 	New: func() interface{} {
 		return new(decoder)
 	},
 }
 
+// countingReader wraps a reader, counting the number of bytes read from it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
 type decoder struct {
 	r  io.Reader
 	cr *Coder
+
+	// Reader used when decoding out of a byte slice (in which case r points at it)
+	src sliceReader
+
+	// Reader used when decoding out of a stream (in which case r points at it)
+	in countingReader
+
+	// If set, we are decoding in zero-copy mode: opaques and strings are returned
+	// as views into src rather than being copied out of it
+	noCopy bool
+
+	// If set, we are in strict mode and validate padding
+	strict bool
+
+	// If set, undeclared enum values are permitted
+	allowUnknownEnums bool
+
+	// Resource limits, and our usage against them
+	limits    xdrinterfaces.Limits
+	usedBytes uint64
+	usedElems uint64
+	depth     int
 }
 
 var _ xdrinterfaces.Decoder = &decoder{}
@@ -88,14 +128,106 @@ func (d *decoder) OpaqueReader(maxLen uint32) (uint32, io.ReadCloser, error) {
 	}
 
 	if l > maxLen {
-		return l, nil, errors.LengthError{uint64(l), uint64(maxLen)}
+		return l, nil, decodeLengthError(d, uint64(l), uint64(maxLen))
 	}
 
 	return l, d.FixedOpaqueReader(l), nil
 }
 
 func (d *decoder) FixedOpaqueReader(len uint32) io.ReadCloser {
-	return newOpaqueReader(d.r, int64(len))
+	return newOpaqueReader(d.r, int64(len), d.strict)
+}
+
+func (d *decoder) ArrayIter(maxLen uint32) (uint32, func(op interface{}) error, error) {
+	// We remain inside the array (for the purposes of the depth limit) until
+	// iteration finishes
+	if err := d.enter(); err != nil {
+		return 0, nil, err
+	}
+
+	l, err := d.DecodeUnsignedInt()
+	if err != nil {
+		d.leave()
+		return 0, nil, err
+	}
+
+	if l > maxLen {
+		d.leave()
+		return l, nil, decodeLengthError(d, uint64(l), uint64(maxLen))
+	}
+
+	// The element type is unknown, so we can only account for their number here.
+	// Their contents are accounted for as they are decoded
+	if err := d.elements(uint64(l), 0); err != nil {
+		d.leave()
+		return l, nil, err
+	}
+
+	var (
+		i       uint32
+		iterErr error
+	)
+
+	// finish ends iteration, with err returned from all further calls
+	finish := func(err error) error {
+		iterErr = err
+		d.leave()
+		return err
+	}
+
+	if l == 0 {
+		finish(io.EOF)
+	}
+
+	next := func(op interface{}) error {
+		switch {
+		case iterErr != nil:
+			return iterErr
+		case i == l:
+			return finish(io.EOF)
+		}
+
+		off := d.Offset()
+		if err := d.Decode(op); err != nil {
+			return finish(errors.WithIndexError(unexpectedEOF(err), off, int(i)))
+		}
+		i++
+		return nil
+	}
+	return l, next, nil
+}
+
+func (d *decoder) Offset() int64 {
+	if d.r == io.Reader(&d.src) {
+		return int64(d.src.off)
+	}
+	return d.in.n
+}
+
+// decodeLengthError returns a LengthError for the length l which was just read
+// from d (and therefore begins 4 bytes before its current offset)
+func decodeLengthError(d xdrinterfaces.Decoder, l, max uint64) error {
+	return errors.LengthError{Actual: l, Max: max, Offset: d.Offset() - 4}
+}
+
+func (d *decoder) SetStrict(strict bool) {
+	d.strict = strict
+}
+
+func (d *decoder) SetAllowUnknownEnums(allow bool) {
+	d.allowUnknownEnums = allow
+}
+
+// checkPadding verifies (if we are in strict mode) that pad is all zeroes
+func (d *decoder) checkPadding(pad []byte) error {
+	if d.strict {
+		for _, b := range pad {
+			if b != 0 {
+				return errors.ErrNonZeroPadding
+			}
+		}
+	}
+	return nil
 }
 
 func (d *decoder) DecodeOpaque(maxLen int) ([]byte, error) {
@@ -108,13 +240,91 @@ func (d *decoder) DecodeOpaque(maxLen int) ([]byte, error) {
 		// for us to do.
 		return nil, nil
 	case uint64(l) > uint64(maxLen):
-		return nil, errors.LengthError{uint64(l), uint64(maxLen)}
+		return nil, decodeLengthError(d, uint64(l), uint64(maxLen))
 	}
 
 	lPad := (int(l) + 3) & ^3
-	buf := make([]byte, lPad)
-	_, err = io.ReadFull(d.r, buf)
-	return buf[0:int(l)], nil
+	if d.noCopy {
+		buf, err := d.src.next(lPad)
+		if err != nil {
+			// We already read the length, so the body is missing
+			return nil, unexpectedEOF(err)
+		}
+		if err := d.checkPadding(buf[int(l):]); err != nil {
+			return nil, err
+		}
+		return buf[0:int(l):int(l)], nil
+	}
+
+	if err := d.allocate(uint64(lPad)); err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	if lPad <= allocChunk {
+		buf = make([]byte, lPad)
+		_, err = io.ReadFull(d.r, buf)
+	} else {
+		buf, err = readGrowing(d.r, lPad)
+	}
+
+	switch err {
+	case nil:
+		if err := d.checkPadding(buf[int(l):]); err != nil {
+			return nil, err
+		}
+		return buf[0:int(l)], nil
+	case io.EOF:
+		// We already read the length, so the body is missing
+		return nil, io.ErrUnexpectedEOF
+	default:
+		return nil, err
+	}
+}
+
+// unexpectedEOF converts an io.EOF error (possibly wrapped in a FieldError) into
+// io.ErrUnexpectedEOF. This is used when decoding the content of an object whose
+// beginning has already been read, where running out of data is not a clean EOF
+func unexpectedEOF(err error) error {
+	switch e := err.(type) {
+	case errors.FieldError:
+		if e.Underlying == io.EOF {
+			e.Underlying = io.ErrUnexpectedEOF
+			return e
+		}
+	default:
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return err
+}
+
+// readGrowing reads n bytes from r into a new buffer which is grown as data arrives
+// (rather than allocated in one go)
+func readGrowing(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, 0, allocChunk)
+	for len(buf) < n {
+		if len(buf) == cap(buf) {
+			newCap := 2 * cap(buf)
+			if newCap > n {
+				newCap = n
+			}
+			nb := make([]byte, len(buf), newCap)
+			copy(nb, buf)
+			buf = nb
+		}
+
+		m, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+		buf = buf[0 : len(buf)+m]
+		switch {
+		case err == io.EOF:
+			return buf, io.ErrUnexpectedEOF
+		case err != nil:
+			return buf, err
+		}
+	}
+	return buf, nil
 }
 
 func (d *decoder) DecodeFixedOpaque(buf []byte) error {
@@ -128,9 +338,13 @@ func (d *decoder) DecodeFixedOpaque(buf []byte) error {
 	// Discard any padding
 	n = ((n + 3) & ^3) - n
 	if n != 0 {
-		n, err = io.ReadFull(d.r, discard[0:n])
+		if _, err = io.ReadFull(d.r, discard[0:n]); err != nil {
+			// We already read the body, so the padding is missing
+			return unexpectedEOF(err)
+		}
+		return d.checkPadding(discard[0:n])
 	}
-	return err
+	return nil
 }
 
 func (d *decoder) DecodeString(maxLen int) (string, error) {
@@ -138,13 +352,32 @@ func (d *decoder) DecodeString(maxLen int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return string(b), err
+
+	// b is either freshly allocated or (in zero-copy mode) a view into the
+	// input; in either case it will never be modified by us, so there is no
+	// need to copy it
+	return bytesToString(b), err
 }
 
 func (d *decoder) DecodeFixedString(len int) (string, error) {
+	if d.noCopy {
+		b, err := d.src.next((len + 3) & ^3)
+		if err != nil {
+			return "", err
+		}
+		if err := d.checkPadding(b[len:]); err != nil {
+			return "", err
+		}
+		return bytesToString(b[0:len]), nil
+	}
+
+	if err := d.allocate(uint64(len)); err != nil {
+		return "", err
+	}
+
 	b := make([]byte, len)
 	err := d.DecodeFixedOpaque(b)
-	return string(b), err
+	return bytesToString(b), err
 }
 
 func (d *decoder) Decode(op interface{}) (err error) {
@@ -156,6 +389,19 @@ func (d *decoder) Decode(op interface{}) (err error) {
 	return d.decodeValue(v.Elem())
 }
 
+// decodeAll decodes op from our source buffer, which (in strict mode) it
+// must entirely consume
+func (d *decoder) decodeAll(op interface{}) error {
+	if err := d.Decode(op); err != nil {
+		return err
+	}
+
+	if d.strict && d.src.off != len(d.src.buf) {
+		return errors.ErrTrailingData
+	}
+	return nil
+}
+
 func (d *decoder) DecodeValue(v reflect.Value) (err error) {
 	if !v.CanSet() {
 		return errors.ErrNotPointer
@@ -170,5 +416,11 @@ func (d *decoder) decodeValue(v reflect.Value) (err error) {
 func (d *decoder) release() {
 	d.r = nil
 	d.cr = nil
+	d.in = countingReader{}
+	d.src.reset(nil)
+	d.noCopy = false
+	d.strict = false
+	d.allowUnknownEnums = false
+	d.depth = 0
 	decoderPool.Put(d)
 }
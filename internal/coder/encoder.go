@@ -47,6 +47,9 @@ type encoder struct {
 
 	// Small scratch buffer (avoids needing to ever allocate when writing primitives)
 	scratch [8]byte
+
+	// If set, map entries are written in order of their encoded keys
+	sortedMaps bool
 }
 
 var _ xdrinterfaces.Encoder = &encoder{}
@@ -67,6 +70,11 @@ func (e *encoder) reset(cr *Coder, w io.Writer) {
 	}
 
 	e.cr = cr
+	e.sortedMaps = cr.sortedMaps
+}
+
+func (w *encoder) SetSortedMaps(sorted bool) {
+	w.sortedMaps = sorted
 }
 
 func (w *encoder) EncodeInt(i int32) error {
@@ -109,7 +117,7 @@ func (w *encoder) EncodeUnsignedHyper(u uint64) error {
 
 func (w *encoder) EncodeOpaque(buf []byte) error {
 	if uint64(len(buf)) > uint64(math.MaxUint32) {
-		return errors.LengthError{uint64(len(buf)), math.MaxUint32}
+		return errors.LengthError{Actual: uint64(len(buf)), Max: math.MaxUint32, Offset: -1}
 	}
 
 	if err := w.EncodeUnsignedInt(uint32(len(buf))); err != nil {
@@ -128,9 +136,38 @@ func (w *encoder) EncodeFixedOpaque(buf []byte) error {
 	return err
 }
 
+func (w *encoder) OpaqueWriter(length uint32) (io.WriteCloser, error) {
+	if err := w.EncodeUnsignedInt(length); err != nil {
+		return nil, err
+	}
+	return w.FixedOpaqueWriter(length), nil
+}
+
+func (w *encoder) FixedOpaqueWriter(length uint32) io.WriteCloser {
+	return newOpaqueWriter(w.w, int64(length))
+}
+
+func (w *encoder) EncodeOpaqueFrom(r io.Reader, n uint32) error {
+	if err := w.EncodeUnsignedInt(n); err != nil {
+		return err
+	}
+
+	// io.CopyN will use r's WriteTo or our writer's ReadFrom method if possible
+	if _, err := io.CopyN(w.w, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	padding := (4 - (n & 3)) & 3
+	_, err := w.w.Write(pad[0:padding])
+	return err
+}
+
 func (w *encoder) EncodeString(s string) error {
 	if uint64(len(s)) > uint64(math.MaxUint32) {
-		return errors.LengthError{uint64(len(s)), math.MaxUint32}
+		return errors.LengthError{Actual: uint64(len(s)), Max: math.MaxUint32, Offset: -1}
 	}
 
 	if err := w.EncodeUnsignedInt(uint32(len(s))); err != nil {
@@ -216,9 +253,64 @@ func (e *marshalEncoder) reset(cr *Coder) {
 	}
 
 	e.cr = cr
+	e.sortedMaps = cr.sortedMaps
 }
 
 func (e *marshalEncoder) release() {
 	e.b.Reset()
 	marshalEncoderPool.Put(e)
 }
+
+// sliceWriter is an io.Writer which appends everything written to it onto a byte slice
+type sliceWriter struct {
+	b []byte
+}
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+func (w *sliceWriter) WriteString(s string) (int, error) {
+	w.b = append(w.b, s...)
+	return len(s), nil
+}
+
+var _ io.Writer = &sliceWriter{}
+var _ io.StringWriter = &sliceWriter{}
+
+var appendEncoderPool = sync.Pool{
+	New: func() interface{} {
+		ae := &appendEncoder{
+			encoder: encoder{
+				codecCacheSlot: 3,
+			},
+		}
+		ae.w = &ae.b
+		ae.ws = &ae.b
+		return ae
+	},
+}
+
+type appendEncoder struct {
+	b sliceWriter
+	encoder
+}
+
+func (e *appendEncoder) reset(cr *Coder, dst []byte) {
+	if e.cr != cr {
+		for i := range e.codecCache {
+			e.codecCache[i].type_ = nil
+			e.codecCache[i].codec = nil
+		}
+	}
+
+	e.cr = cr
+	e.sortedMaps = cr.sortedMaps
+	e.b.b = dst
+}
+
+func (e *appendEncoder) release() {
+	e.b.b = nil
+	appendEncoderPool.Put(e)
+}
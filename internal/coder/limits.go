@@ -0,0 +1,85 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	xdrinterfaces "go.e43.eu/xdr/interfaces"
+	"go.e43.eu/xdr/internal/errors"
+)
+
+func (d *decoder) SetLimits(l xdrinterfaces.Limits) {
+	d.limits = l
+	d.usedBytes = 0
+	d.usedElems = 0
+}
+
+// allocate accounts for the allocation of n bytes
+func (d *decoder) allocate(n uint64) error {
+	if max := d.limits.MaxBytes; max != 0 && n > max-d.usedBytes {
+		return errors.LimitError{Kind: errors.LimitBytes, Requested: d.usedBytes + n, Max: max}
+	}
+	d.usedBytes += n
+	return nil
+}
+
+// elements accounts for the decoding of n array or map elements, each of which
+// occupies size bytes in memory
+func (d *decoder) elements(n uint64, size uintptr) error {
+	if max := d.limits.MaxElements; max != 0 && n > max-d.usedElems {
+		return errors.LimitError{Kind: errors.LimitElements, Requested: d.usedElems + n, Max: max}
+	}
+	d.usedElems += n
+
+	// n is at most 2^32, so this cannot overflow for any type which fits in memory
+	return d.allocate(n * uint64(size))
+}
+
+// enter is called when a compound type begins decoding
+func (d *decoder) enter() error {
+	if max := d.limits.MaxDepth; max != 0 && d.depth >= max {
+		return errors.LimitError{Kind: errors.LimitDepth, Requested: uint64(d.depth) + 1, Max: uint64(max)}
+	}
+	d.depth++
+	return nil
+}
+
+// leave is called when a compound type finishes decoding
+func (d *decoder) leave() {
+	d.depth--
+}
+
+// The codecs receive an xdrinterfaces.Decoder. In practice, this is always one of
+// ours; but we tolerate other implementations by not applying any limits to them
+
+// decodeAllocate accounts for the allocation of n bytes on d
+func decodeAllocate(d xdrinterfaces.Decoder, n uintptr) error {
+	if dd, ok := d.(*decoder); ok {
+		return dd.allocate(uint64(n))
+	}
+	return nil
+}
+
+// decodeElements accounts for the decoding of n elements of the specified size on d
+func decodeElements(d xdrinterfaces.Decoder, n uint32, size uintptr) error {
+	if dd, ok := d.(*decoder); ok {
+		return dd.elements(uint64(n), size)
+	}
+	return nil
+}
+
+// decodeEnter is called when starting to decode a compound type. If it succeeds,
+// decodeLeave must be called after decoding of the type has finished
+func decodeEnter(d xdrinterfaces.Decoder) error {
+	if dd, ok := d.(*decoder); ok {
+		return dd.enter()
+	}
+	return nil
+}
+
+// decodeLeave is called after decoding a compound type
+func decodeLeave(d xdrinterfaces.Decoder) {
+	if dd, ok := d.(*decoder); ok {
+		dd.leave()
+	}
+}
@@ -6,20 +6,24 @@ package coder
 import (
 	"io"
 	"io/ioutil"
+
+	"go.e43.eu/xdr/internal/errors"
 )
 
 type opaqueReader struct {
 	lr     io.LimitedReader
 	padLen byte
+	strict bool
 }
 
-func newOpaqueReader(r io.Reader, len int64) *opaqueReader {
+func newOpaqueReader(r io.Reader, len int64, strict bool) *opaqueReader {
 	return &opaqueReader{
 		lr: io.LimitedReader{
 			R: r,
 			N: len,
 		},
 		padLen: uint8(((len + 3) & ^3) - len),
+		strict: strict,
 	}
 }
 
@@ -32,9 +36,27 @@ func (o *opaqueReader) WriteTo(w io.Writer) (int64, error) {
 }
 
 func (o *opaqueReader) Close() error {
-	o.lr.N += int64(o.padLen)
-	_, err := io.Copy(ioutil.Discard, &o.lr)
-	return err
+	// Discard anything remaining of the body
+	remaining := o.lr.N
+	if n, err := io.Copy(ioutil.Discard, &o.lr); err != nil {
+		return err
+	} else if n != remaining {
+		return io.ErrUnexpectedEOF
+	}
+
+	var padding [3]byte
+	if _, err := io.ReadFull(o.lr.R, padding[0:o.padLen]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	o.padLen = 0
+
+	if o.strict && padding != [3]byte{} {
+		return errors.ErrNonZeroPadding
+	}
+	return nil
 }
 
 var _ io.Reader = &opaqueReader{}
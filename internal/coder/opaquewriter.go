@@ -0,0 +1,79 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"io"
+
+	"go.e43.eu/xdr/internal/errors"
+)
+
+type opaqueWriter struct {
+	w         io.Writer
+	remaining int64
+	padLen    byte
+}
+
+func newOpaqueWriter(w io.Writer, len int64) *opaqueWriter {
+	return &opaqueWriter{
+		w:         w,
+		remaining: len,
+		padLen:    uint8(((len + 3) & ^3) - len),
+	}
+}
+
+func (o *opaqueWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > o.remaining {
+		n, err := o.w.Write(p[0:o.remaining])
+		o.remaining -= int64(n)
+		if err == nil {
+			err = errors.ErrLengthIncorrect
+		}
+		return n, err
+	}
+
+	n, err := o.w.Write(p)
+	o.remaining -= int64(n)
+	return n, err
+}
+
+func (o *opaqueWriter) ReadFrom(r io.Reader) (int64, error) {
+	// io.Copy will make use of the underlying writer's ReadFrom method, if any
+	n, err := io.Copy(o.w, &io.LimitedReader{R: r, N: o.remaining})
+	o.remaining -= n
+	if err != nil || o.remaining != 0 {
+		return n, err
+	}
+
+	// We have written everything we expected to; make sure that r is exhausted.
+	// If we can, we put back the byte we probed with; otherwise it is consumed
+	if bs, ok := r.(io.ByteScanner); ok {
+		if _, err := bs.ReadByte(); err != nil {
+			return n, nil
+		}
+		bs.UnreadByte()
+		return n, errors.ErrLengthIncorrect
+	}
+
+	var probe [1]byte
+	if m, _ := io.ReadFull(r, probe[:]); m != 0 {
+		return n, errors.ErrLengthIncorrect
+	}
+	return n, nil
+}
+
+// Close checks that the whole body was written, then writes the padding
+func (o *opaqueWriter) Close() error {
+	if o.remaining != 0 {
+		return errors.ErrLengthIncorrect
+	}
+
+	_, err := o.w.Write(pad[0:o.padLen])
+	o.padLen = 0
+	return err
+}
+
+var _ io.Writer = &opaqueWriter{}
+var _ io.WriteCloser = &opaqueWriter{}
+var _ io.ReaderFrom = &opaqueWriter{}
@@ -0,0 +1,203 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// fixedSizer is implemented by codecs which may know the encoded size of
+// their type without looking at a value.
+//
+// A codec may only report a fixed size if every value of the type encodes
+// to that many bytes *and* encoding can never fail (i.e. it does no
+// validation), as we use this to skip encoding entirely
+type fixedSizer interface {
+	// fixedSize returns the encoded size in bytes, or -1 if it is not fixed
+	fixedSize() int
+}
+
+// fixedSize returns the fixed encoded size of values handled by c, or -1 if
+// their size varies (or c does not know)
+func fixedSize(c xCodec) int {
+	if fs, ok := c.(fixedSizer); ok {
+		return fs.fixedSize()
+	}
+	return -1
+}
+
+// padder is implemented by fixed size codecs whose encoding may contain padding
+// (which must be validated in strict mode, so cannot just be skipped over)
+type padder interface {
+	hasPadding() bool
+}
+
+// hasPadding returns whether values handled by c contain padding
+func hasPadding(c xCodec) bool {
+	if p, ok := c.(padder); ok {
+		return p.hasPadding()
+	}
+	return false
+}
+
+func (_ boolCodec) fixedSize() int       { return 4 }
+func (_ intCodec) fixedSize() int        { return 4 }
+func (_ uintCodec) fixedSize() int       { return 4 }
+func (_ hyperCodec) fixedSize() int      { return 8 }
+func (_ uhyperCodec) fixedSize() int     { return 8 }
+func (_ floatCodec) fixedSize() int      { return 4 }
+func (_ doubleCodec) fixedSize() int     { return 8 }
+func (_ complex64Codec) fixedSize() int  { return 8 }
+func (_ complex128Codec) fixedSize() int { return 16 }
+func (_ quadrupleCodec) fixedSize() int  { return 16 }
+
+func (c *opaqueArrayCodec) fixedSize() int {
+	return (c.len + 3) & ^3
+}
+
+func (c *opaqueArrayCodec) hasPadding() bool {
+	return c.len%4 != 0
+}
+
+func (c *arrayCodec) fixedSize() int {
+	es := fixedSize(c.elem)
+	if es < 0 {
+		return -1
+	}
+	return es * c.len
+}
+
+func (c *arrayCodec) hasPadding() bool {
+	return c.len != 0 && hasPadding(c.elem)
+}
+
+func (c *structCodec) fixedSize() int {
+	c.sizeOnce.Do(c.computeSize)
+	return c.size
+}
+
+func (c *structCodec) hasPadding() bool {
+	c.sizeOnce.Do(c.computeSize)
+	return c.padded
+}
+
+func (c *structCodec) computeSize() {
+	c.size = 0
+	for i := range c.fields {
+		fs := fixedSize(c.fields[i].codec)
+		if fs < 0 || len(c.fields[i].embed) != 0 {
+			// (Encoding fails if an embedded pointer is nil)
+			c.size = -1
+			return
+		}
+		c.size += fs
+		c.padded = c.padded || hasPadding(c.fields[i].codec)
+	}
+}
+
+func (dc *deferredCodec) fixedSize() int {
+	real := dc.real.Load()
+	if real == nil {
+		dc.wg.Wait()
+		real = dc.real.Load()
+	}
+	return fixedSize(real.(xCodec))
+}
+
+func (dc *deferredCodec) hasPadding() bool {
+	real := dc.real.Load()
+	if real == nil {
+		dc.wg.Wait()
+		real = dc.real.Load()
+	}
+	return hasPadding(real.(xCodec))
+}
+
+// countingWriter is an io.Writer which discards everything written to it,
+// counting the number of bytes
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	w.n += int64(len(s))
+	return len(s), nil
+}
+
+var _ io.Writer = &countingWriter{}
+var _ io.StringWriter = &countingWriter{}
+
+var sizeEncoderPool = sync.Pool{
+	New: func() interface{} {
+		se := &sizeEncoder{
+			encoder: encoder{
+				codecCacheSlot: 3,
+			},
+		}
+		se.w = &se.c
+		se.ws = &se.c
+		return se
+	},
+}
+
+// sizeEncoder is an encoder which only counts the bytes it would have written
+type sizeEncoder struct {
+	c countingWriter
+	encoder
+}
+
+func (e *sizeEncoder) reset(cr *Coder) {
+	if e.cr != cr {
+		for i := range e.codecCache {
+			e.codecCache[i].type_ = nil
+			e.codecCache[i].codec = nil
+		}
+	}
+
+	e.cr = cr
+	e.c.n = 0
+}
+
+func (e *sizeEncoder) release() {
+	sizeEncoderPool.Put(e)
+}
+
+// encodedFixedSize returns the fixed size of values of type t (or -1 if they
+// vary in size), caching the result
+func (cr *Coder) encodedFixedSize(t reflect.Type) int {
+	if sz, ok := cr.knownSizes.Load(t); ok {
+		return sz.(int)
+	}
+
+	sz := fixedSize(cr.getBaseCodec(t))
+	cr.knownSizes.Store(t, sz)
+	return sz
+}
+
+func (cr *Coder) EncodedSize(o interface{}) (int, error) {
+	// Pointers are transparent (and only fail to encode if nil), so look
+	// through them in order to find a fixed size type
+	v := reflect.ValueOf(o)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if sz := cr.encodedFixedSize(v.Type()); sz >= 0 {
+		return sz, nil
+	}
+
+	e := sizeEncoderPool.Get().(*sizeEncoder)
+	defer e.release()
+
+	e.reset(cr)
+	err := e.EncodeValue(v)
+	return int(e.c.n), err
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"go.e43.eu/xdr/internal/errors"
+)
+
+// skipper is implemented by codecs which are able to skip over a value in the
+// stream without decoding it. t is the type handled by the codec
+type skipper interface {
+	skip(d *decoder, t reflect.Type) error
+}
+
+// skipValue skips over a value of type t handled by the codec c
+//
+// Codecs which know their encoded size are skipped by discarding that many bytes.
+// Codecs which are neither fixed size nor able to skip themselves (i.e. custom
+// codecs and Marshaler implementations) are skipped by decoding into a
+// temporary value
+func skipValue(d *decoder, c xCodec, t reflect.Type) error {
+	if s, ok := c.(skipper); ok {
+		return s.skip(d, t)
+	} else if fs := d.discardSize(c); fs >= 0 {
+		return d.discard(fs)
+	}
+
+	v := reflect.New(t).Elem()
+	if t.Kind() == reflect.Ptr {
+		// e.g. a pointer type implementing Marshaler
+		v.Set(reflect.New(t.Elem()))
+	}
+	return c.Decode(d, v)
+}
+
+func (d *decoder) Skip(t reflect.Type) error {
+	return skipValue(d, d.cr.getBaseCodec(t), t)
+}
+
+// discardSize returns the number of bytes which may be discarded to skip a value
+// handled by c, or -1 if it must be skipped piecewise. (In strict mode, values
+// containing padding must be examined so that the padding may be validated)
+func (d *decoder) discardSize(c xCodec) int {
+	if d.strict && hasPadding(c) {
+		return -1
+	}
+	return fixedSize(c)
+}
+
+// discard skips n bytes of input
+func (d *decoder) discard(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	if d.r == io.Reader(&d.src) {
+		_, err := d.src.next(n)
+		return err
+	}
+
+	m, err := io.CopyN(ioutil.Discard, d.r, int64(n))
+	switch {
+	case err == io.EOF && m != 0:
+		return io.ErrUnexpectedEOF
+	default:
+		return err
+	}
+}
+
+// skipOpaque skips the body of an opaque of length n, plus its padding
+func (d *decoder) skipOpaque(n int) error {
+	if err := d.discard(n); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	var pad [3]byte
+	if p := ((n + 3) & ^3) - n; p != 0 {
+		if _, err := io.ReadFull(d.r, pad[0:p]); err != nil {
+			return unexpectedEOF(err)
+		}
+		return d.checkPadding(pad[0:p])
+	}
+	return nil
+}
+
+// skipLength reads the length of a variable length object and checks it against max
+func (d *decoder) skipLength(max int, origMax uint32) (int, error) {
+	l, err := d.DecodeUnsignedInt()
+	switch {
+	case err != nil:
+		return 0, err
+	case uint64(l) > uint64(max):
+		return 0, decodeLengthError(d, uint64(l), uint64(origMax))
+	}
+	return int(l), nil
+}
+
+func (c *errorCodec) skip(d *decoder, t reflect.Type) error {
+	return c.err
+}
+
+func (dc *deferredCodec) skip(d *decoder, t reflect.Type) error {
+	real := dc.real.Load()
+	if real == nil {
+		dc.wg.Wait()
+		real = dc.real.Load()
+	}
+	return skipValue(d, real.(xCodec), t)
+}
+
+func (c *fixedStringCodec) skip(d *decoder, t reflect.Type) error {
+	return d.skipOpaque(c.len)
+}
+
+func (c *varStringCodec) skip(d *decoder, t reflect.Type) error {
+	l, err := d.skipLength(c.maxlen, c.origMax)
+	if err != nil {
+		return err
+	}
+	return d.skipOpaque(l)
+}
+
+func (c *opaqueArrayCodec) skip(d *decoder, t reflect.Type) error {
+	return d.skipOpaque(c.len)
+}
+
+func (c *opaqueSliceCodec) skip(d *decoder, t reflect.Type) error {
+	l, err := d.skipLength(c.maxlen, c.origMax)
+	if err != nil {
+		return err
+	}
+	return d.skipOpaque(l)
+}
+
+func (c *fixedOpaqueSliceCodec) skip(d *decoder, t reflect.Type) error {
+	return d.skipOpaque(c.len)
+}
+
+// skipElements skips n elements of type t handled by c
+func (d *decoder) skipElements(c xCodec, t reflect.Type, n int) error {
+	if fs := d.discardSize(c); fs >= 0 {
+		// Compute in 64 bits so that we cannot overflow on 32-bit platforms
+		if sz := uint64(fs) * uint64(n); sz <= uint64(maxInt) {
+			return d.discard(int(sz))
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		off := d.Offset()
+		if err := skipValue(d, c, t); err != nil {
+			return errors.WithIndexError(err, off, i)
+		}
+	}
+	return nil
+}
+
+func (c *arrayCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	return d.skipElements(c.elem, t.Elem(), c.len)
+}
+
+func (c *sliceCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	l, err := d.skipLength(c.maxlen, c.origMax)
+	if err != nil {
+		return err
+	}
+	return unexpectedEOF(d.skipElements(c.elem, t.Elem(), l))
+}
+
+func (c *fixedSliceCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	return d.skipElements(c.elem, t.Elem(), c.len)
+}
+
+func (c *optListCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	for i := 0; ; i++ {
+		more, err := d.DecodeBool()
+		switch {
+		case err != nil && i != 0:
+			return unexpectedEOF(err)
+		case err != nil:
+			return err
+		case !more:
+			return nil
+		case i == c.maxlen:
+			return decodeLengthError(d, uint64(i)+1, uint64(c.origMax))
+		}
+
+		off := d.Offset()
+		if err := skipValue(d, c.elem, t.Elem()); err != nil {
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
+		}
+	}
+}
+
+func (c *mapCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	l, err := d.skipLength(c.maxlen, c.origMax)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < l; i++ {
+		off := d.Offset()
+		if err := skipValue(d, c.keyCodec, c.kt); err != nil {
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
+		}
+
+		if err := skipValue(d, c.valueCodec, c.vt); err != nil {
+			return errors.WithIndexError(unexpectedEOF(err), off, i)
+		}
+	}
+	return nil
+}
+
+func (c *structCodec) skip(d *decoder, t reflect.Type) error {
+	if fs := d.discardSize(c); fs >= 0 {
+		return d.discard(fs)
+	}
+
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	start := d.Offset()
+	for i := range c.fields {
+		f := &c.fields[i]
+		off := d.Offset()
+		if err := skipValue(d, f.codec, f.t); err != nil {
+			if off != start {
+				err = unexpectedEOF(err)
+			}
+			return errors.WithFieldError(err, off, c.name, f.name)
+		}
+	}
+	return nil
+}
+
+func (c *switchCodec) skip(d *decoder, t reflect.Type) error {
+	return d.discard(4)
+}
+
+func (c *unionCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	// The switch may be of any width, so decode it in order to find its value
+	swOff := d.Offset()
+	swv := reflect.New(c.switchField.t).Elem()
+	if err := c.switchField.codec.Decode(d, swv); err != nil {
+		return errors.WithFieldError(err, swOff, c.name, c.switchField.name)
+	}
+
+	swVal := c.switchKey(swv)
+	caseField, exists := c.cases[swVal]
+	if !exists {
+		caseField = c.defaultCase
+	}
+
+	if caseField == -1 {
+		err := errors.ErrUnionSwitchArmUndefined
+		return errors.WithFieldError(err, swOff, c.name, c.switchField.name)
+	}
+
+	f := &c.bodyFields[caseField]
+	off := d.Offset()
+	if err := skipValue(d, f.codec, f.t); err != nil {
+		err = unexpectedEOF(err)
+		return c.armError(err, off, f.name, swVal)
+	}
+	return nil
+}
+
+func (c *interfaceCodec) skip(d *decoder, t reflect.Type) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	disc, err := d.DecodeInt()
+	if err != nil {
+		return err
+	}
+
+	at, ok := c.types[disc]
+	if !ok {
+		return errors.ErrUnionSwitchArmUndefined
+	}
+
+	off := d.Offset()
+	if err := skipValue(d, c.cr.getBaseCodec(at), at); err != nil {
+		return errors.WithUnionArmError(unexpectedEOF(err), off, c.t.Name(), at.String(), disc, "")
+	}
+	return nil
+}
+
+func (c *optCodec) skip(d *decoder, t reflect.Type) error {
+	isNonNil, err := d.DecodeBool()
+	if err != nil || !isNonNil {
+		return err
+	}
+	return unexpectedEOF(skipValue(d, c.elem, t))
+}
+
+func (c *ptrCodec) skip(d *decoder, t reflect.Type) error {
+	return skipValue(d, c.elem, c.elemt)
+}
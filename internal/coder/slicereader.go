@@ -0,0 +1,56 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package coder
+
+import (
+	"io"
+)
+
+// sliceReader is an io.Reader which reads from a byte slice. Unlike bytes.Reader
+// it is also able to hand out views directly into the underlying buffer, which
+// we use to implement zero-copy decoding
+type sliceReader struct {
+	buf []byte
+	off int
+}
+
+var _ io.Reader = &sliceReader{}
+
+func (r *sliceReader) reset(buf []byte) {
+	r.buf = buf
+	r.off = 0
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.buf) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.buf[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// next returns the next n bytes of the buffer without copying them. The returned
+// slice has its capacity limited to n, so that appending to it will never clobber
+// the remainder of the buffer
+//
+// Errors are returned following the conventions of io.ReadFull
+func (r *sliceReader) next(n int) ([]byte, error) {
+	rem := len(r.buf) - r.off
+	switch {
+	case rem >= n:
+		b := r.buf[r.off : r.off+n : r.off+n]
+		r.off += n
+		return b, nil
+	case rem == 0:
+		return nil, io.EOF
+	default:
+		r.off = len(r.buf)
+		return nil, io.ErrUnexpectedEOF
+	}
+}
@@ -57,6 +57,25 @@ const (
 
 	// Pointer was unexpectedly nil
 	ErrNilPointer = xerror("xdr: Unexpected nil pointer")
+
+	// Decoding would exceed one of the decoder's resource limits
+	ErrLimitExceeded = xerror("xdr: Decode resource limit exceeded")
+
+	// Padding bytes were not zero (only detected in strict mode)
+	ErrNonZeroPadding = xerror("xdr: Non-zero padding")
+
+	// Data remained in the buffer after the decoded object (only detected in strict mode)
+	ErrTrailingData = xerror("xdr: Trailing data after object")
+
+	// Integer out of range for the type it is being encoded as or decoded into
+	ErrIntegerOverflow = xerror("xdr: Integer overflow")
+
+	// Value decoded for an enum is not one of its declared values
+	ErrInvalidEnumValue = xerror("xdr: Invalid enum value")
+
+	// Populated arms of a union do not agree with its switch (or multiple arms are
+	// populated). Only detected for unions with an inferred switch
+	ErrUnionArmMismatch = xerror("xdr: Union arm does not match switch")
 )
 
 type InvalidTypeError struct {
@@ -76,8 +95,11 @@ func (e InvalidTagForTypeError) Error() string {
 	return fmt.Sprintf("xdr: Tag '%s' unsupported for type '%s'", e.Tag, e.T)
 }
 
+// LengthError is returned when a variable length object is too long. Offset is the
+// position of the object's length in the stream when decoding, and -1 when encoding
 type LengthError struct {
 	Actual, Max uint64
+	Offset      int64
 }
 
 func (err LengthError) Is(target error) bool {
@@ -92,51 +114,235 @@ func (err LengthError) Is(target error) bool {
 }
 
 func (err LengthError) Error() string {
+	var msg string
 	if err.Actual > err.Max {
-		return fmt.Sprintf("%s (%d > %d)", ErrLengthExceedsMax, err.Actual, err.Max)
+		msg = fmt.Sprintf("%s (%d > %d", ErrLengthExceedsMax, err.Actual, err.Max)
 	} else {
-		return fmt.Sprintf("%s (%d > %d)", ErrLengthExceedsPlatformLimit, err.Actual, maxInt)
+		msg = fmt.Sprintf("%s (%d > %d", ErrLengthExceedsPlatformLimit, err.Actual, maxInt)
+	}
+
+	if err.Offset >= 0 {
+		msg += fmt.Sprintf(", offset 0x%x", err.Offset)
+	}
+	return msg + ")"
+}
+
+// OverflowError is returned when an integer is out of range for the type it is
+// being encoded as or decoded into. Value is an int64 or a uint64, and Type names
+// the Go or XDR type which could not represent it
+type OverflowError struct {
+	Value interface{}
+	Type  string
+}
+
+func (err OverflowError) Is(target error) bool {
+	return target == ErrIntegerOverflow
+}
+
+func (err OverflowError) Error() string {
+	return fmt.Sprintf("%s (%v does not fit in %s)", ErrIntegerOverflow, err.Value, err.Type)
+}
+
+// EnumValueError is returned when a value decoded for an enum is not one of
+// those declared when it was registered. Type names the enum type, and Declared
+// the names of its declared values (in ascending order of value)
+type EnumValueError struct {
+	Value    int32
+	Type     string
+	Declared []string
+}
+
+func (err EnumValueError) Is(target error) bool {
+	return target == ErrInvalidEnumValue
+}
+
+func (err EnumValueError) Error() string {
+	if len(err.Declared) == 0 {
+		return fmt.Sprintf("%s (%d is not a declared value of %s)", ErrInvalidEnumValue, err.Value, err.Type)
+	}
+	return fmt.Sprintf("%s (%d is not a declared value of %s; expected one of %s)",
+		ErrInvalidEnumValue, err.Value, err.Type, strings.Join(err.Declared, ", "))
+}
+
+// LimitKind identifies one of the resource limits which may be imposed upon a decoder
+type LimitKind int
+
+const (
+	// Limit on the total number of bytes allocated
+	LimitBytes LimitKind = iota
+	// Limit on the total number of variable length array and map elements
+	LimitElements
+	// Limit on the nesting depth of compound types
+	LimitDepth
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitBytes:
+		return "bytes"
+	case LimitElements:
+		return "elements"
+	case LimitDepth:
+		return "depth"
+	default:
+		return fmt.Sprintf("LimitKind(%d)", int(k))
+	}
+}
+
+// LimitError is returned when decoding would cause a decoder to exceed one of its
+// resource limits. Requested is the total usage that would have resulted
+type LimitError struct {
+	Kind           LimitKind
+	Requested, Max uint64
+}
+
+func (err LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+func (err LimitError) Error() string {
+	return fmt.Sprintf("%s (%s: %d > %d)", ErrLimitExceeded, err.Kind, err.Requested, err.Max)
+}
+
+// PathElemKind identifies the kind of a PathElem
+type PathElemKind int
+
+const (
+	// A field of a structure
+	PathField PathElemKind = iota
+	// The selected arm of a union
+	PathUnionArm
+	// An element of an array or slice
+	PathIndex
+	// An entry in a map
+	PathMapKey
+)
+
+func (k PathElemKind) String() string {
+	switch k {
+	case PathField:
+		return "field"
+	case PathUnionArm:
+		return "union arm"
+	case PathIndex:
+		return "index"
+	case PathMapKey:
+		return "map key"
+	default:
+		return fmt.Sprintf("PathElemKind(%d)", int(k))
 	}
 }
 
+// PathElem is one step of the path to the object at which an error occurred
+type PathElem struct {
+	Kind PathElemKind
+
+	// For PathField and PathUnionArm: The name of the structure or union type
+	// (empty if anonymous) and of the field
+	Type, Name string
+
+	// For PathUnionArm: The value of the union's switch, and its name if the
+	// switch is a registered enum (or empty otherwise)
+	Case     interface{}
+	CaseName string
+
+	// For PathIndex: The index of the element
+	Index int
+
+	// For PathMapKey: The key of the entry (nil if it was inaccessible)
+	Key interface{}
+}
+
+// FieldError wraps an error which occurred while encoding or decoding a
+// component (field, element or map entry) of a compound object. Path is the
+// path from the outermost object to the component. Offset is the position in the
+// stream of the innermost component in which the error occurred when decoding,
+// and -1 when encoding
 type FieldError struct {
 	Underlying error
-	Path       string
+	Path       []PathElem
+	Offset     int64
 }
 
 func (err FieldError) Unwrap() error {
 	return err.Underlying
 }
 
+// PathString formats the path in the style of a Go expression, for example
+// "Foo.Entries[17].Name". Union arms selected by a named enum value are annotated
+// with that name, for example "Res.Ok<NFS3_OK>.Size"
+func (err FieldError) PathString() string {
+	var sb strings.Builder
+	for i, e := range err.Path {
+		switch e.Kind {
+		case PathField, PathUnionArm:
+			if i == 0 {
+				if e.Type == "" {
+					sb.WriteString("<anonymous>")
+				} else {
+					sb.WriteString(e.Type)
+				}
+			}
+			sb.WriteByte('.')
+			sb.WriteString(e.Name)
+			if e.Kind == PathUnionArm && e.CaseName != "" {
+				fmt.Fprintf(&sb, "<%s>", e.CaseName)
+			}
+		case PathIndex:
+			fmt.Fprintf(&sb, "[%d]", e.Index)
+		case PathMapKey:
+			if k, ok := e.Key.(string); ok {
+				fmt.Fprintf(&sb, "[%q]", k)
+			} else {
+				fmt.Fprintf(&sb, "[%v]", e.Key)
+			}
+		}
+	}
+	return sb.String()
+}
+
 func (err FieldError) Error() string {
 	uerr := strings.TrimPrefix(err.Underlying.Error(), "xdr: ")
-	return fmt.Sprintf("xdr: %s (at %s)", uerr, err.Path)
+	if err.Offset >= 0 {
+		return fmt.Sprintf("xdr: %s (at %s, offset 0x%x)", uerr, err.PathString(), err.Offset)
+	}
+	return fmt.Sprintf("xdr: %s (at %s)", uerr, err.PathString())
 }
 
-func WithFieldError(err error, parts ...string) error {
+// WithPathElem wraps err (if non-nil) in a FieldError, prepending elem to its path.
+// offset is the position at which the component began when decoding (or -1 when
+// encoding); if err is already a FieldError, its (more precise) offset is retained
+func WithPathElem(err error, offset int64, elem PathElem) error {
 	if err == nil {
 		return nil
 	}
 
-	var combined string
-	if parts[0] == "" {
-		parts[0] = "<anonymous>"
-	}
-
-	switch len(parts) {
-	case 1:
-		combined = parts[0]
-	case 3:
-		combined = fmt.Sprintf("%s.%s(%s)", parts[0], parts[1], parts[2])
-	default:
-		combined = strings.Join(parts, ".")
-	}
-
 	switch err := err.(type) {
 	case FieldError:
-		err.Path = fmt.Sprintf("%s %s", combined, err.Path)
+		err.Path = append([]PathElem{elem}, err.Path...)
 		return err
 	default:
-		return FieldError{err, combined}
+		return FieldError{Underlying: err, Path: []PathElem{elem}, Offset: offset}
 	}
 }
+
+// WithFieldError wraps err with the path to field of the structure type typ
+func WithFieldError(err error, offset int64, typ, field string) error {
+	return WithPathElem(err, offset, PathElem{Kind: PathField, Type: typ, Name: field})
+}
+
+// WithUnionArmError wraps err with the path to the arm field of the union type typ,
+// selected by the switch value c (named name, if it is a named enum value)
+func WithUnionArmError(err error, offset int64, typ, field string, c interface{}, name string) error {
+	return WithPathElem(err, offset, PathElem{Kind: PathUnionArm, Type: typ, Name: field, Case: c, CaseName: name})
+}
+
+// WithIndexError wraps err with the path to element i of an array or slice
+func WithIndexError(err error, offset int64, i int) error {
+	return WithPathElem(err, offset, PathElem{Kind: PathIndex, Index: i})
+}
+
+// WithKeyError wraps err with the path to the entry with key k of a map
+func WithKeyError(err error, offset int64, k interface{}) error {
+	return WithPathElem(err, offset, PathElem{Kind: PathMapKey, Key: k})
+}
@@ -0,0 +1,217 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+// Package quadruple implements the XDR quadruple type (an IEEE 754 binary128
+// floating point number)
+package quadruple
+
+import (
+	"math"
+	"math/big"
+)
+
+const (
+	// Number of explicitly stored significand (fraction) bits
+	fracBits = 112
+	// Number of significand bits, including the implicit leading bit
+	precision = fracBits + 1
+	// Exponent bias, which is also the maximum exponent of a finite value
+	bias = 16383
+	// Exponent of normal values with the smallest magnitude
+	minExp = 1 - bias
+	// Exponent of the least significant bit of subnormal values
+	minSubnormalExp = minExp - fracBits
+	// Biased exponent of infinities and NaNs
+	expMask = 0x7fff
+
+	// Mask of the bits of the fraction stored in Hi
+	hiFracMask = 1<<(fracBits-64) - 1
+)
+
+// Quadruple is an IEEE 754 binary128 (quadruple precision) floating point number,
+// which is the XDR `quadruple` type. Hi holds the sign bit, exponent and most
+// significant 48 bits of the fraction; Lo holds the rest of the fraction.
+//
+// Go has no native quadruple precision type, so conversions to and from float64
+// and *big.Float are provided
+type Quadruple struct {
+	Hi, Lo uint64
+}
+
+func (q Quadruple) signbit() bool {
+	return q.Hi>>63 != 0
+}
+
+func (q Quadruple) biasedExp() int {
+	return int(q.Hi>>(fracBits-64)) & expMask
+}
+
+func (q Quadruple) fracIsZero() bool {
+	return q.Hi&hiFracMask == 0 && q.Lo == 0
+}
+
+// IsNaN returns whether q is a NaN
+func (q Quadruple) IsNaN() bool {
+	return q.biasedExp() == expMask && !q.fracIsZero()
+}
+
+// IsInf returns whether q is an infinity, according to sign. If sign > 0, IsInf
+// returns whether q is positive infinity; if sign < 0, whether it is negative
+// infinity; if sign == 0, whether it is either.
+func (q Quadruple) IsInf(sign int) bool {
+	if q.biasedExp() != expMask || !q.fracIsZero() {
+		return false
+	}
+	return sign == 0 || (sign > 0) == !q.signbit()
+}
+
+// Inf returns positive infinity if sign >= 0, negative infinity if sign < 0
+func Inf(sign int) Quadruple {
+	q := Quadruple{Hi: expMask << (fracBits - 64)}
+	if sign < 0 {
+		q.Hi |= 1 << 63
+	}
+	return q
+}
+
+// NaN returns a (quiet) NaN
+func NaN() Quadruple {
+	return Quadruple{Hi: expMask<<(fracBits-64) | 1<<(fracBits-65)}
+}
+
+// BigFloat returns the value of q as a *big.Float with 113 bits of precision.
+// This conversion is exact. As big.Float cannot represent NaN, nil is returned
+// if q is a NaN
+func (q Quadruple) BigFloat() *big.Float {
+	exp := q.biasedExp()
+	frac := new(big.Int).SetUint64(q.Hi & hiFracMask)
+	frac.Lsh(frac, 64).Or(frac, new(big.Int).SetUint64(q.Lo))
+
+	z := new(big.Float).SetPrec(precision)
+	switch exp {
+	case expMask:
+		if frac.Sign() != 0 {
+			return nil
+		}
+		return z.SetInf(q.signbit())
+
+	case 0:
+		// Zero or subnormal: value is frac * 2^minSubnormalExp
+		z.SetMantExp(z.SetInt(frac), minSubnormalExp)
+
+	default:
+		// Normal: value is 1.frac * 2^(exp - bias)
+		frac.SetBit(frac, fracBits, 1)
+		z.SetMantExp(z.SetInt(frac), exp-bias-fracBits)
+	}
+
+	if q.signbit() {
+		z.Neg(z)
+	}
+	return z
+}
+
+// Float64 returns the float64 nearest to q, rounding ties to even. Values too
+// large in magnitude for a float64 become infinities, and values too small become
+// zero (or subnormal float64s) of the same sign.
+//
+// NaNs are converted to NaNs, preserving the sign and the most significant bits
+// of the payload
+func (q Quadruple) Float64() float64 {
+	if q.IsNaN() {
+		bits := q.Hi&(1<<63) | 0x7ff<<52 | (q.Hi&hiFracMask)<<4 | q.Lo>>60
+		if bits&(1<<52-1) == 0 {
+			// Payload entirely in discarded bits; make sure we're still a NaN
+			bits |= 1 << 51
+		}
+		return math.Float64frombits(bits)
+	}
+
+	f, _ := q.BigFloat().Float64()
+	return f
+}
+
+// FromFloat64 returns f as a Quadruple. This conversion is exact.
+//
+// NaNs are converted to NaNs, preserving the sign and payload
+func FromFloat64(f float64) Quadruple {
+	if math.IsNaN(f) {
+		bits := math.Float64bits(f)
+		frac := bits & (1<<52 - 1)
+		return Quadruple{
+			Hi: bits&(1<<63) | expMask<<(fracBits-64) | frac>>4,
+			Lo: frac << 60,
+		}
+	}
+
+	return FromBigFloat(new(big.Float).SetFloat64(f))
+}
+
+// FromBigFloat returns the Quadruple nearest to x, rounding ties to even. Values
+// too large in magnitude become infinities, and values too small become zero (or
+// subnormals) of the same sign
+func FromBigFloat(x *big.Float) Quadruple {
+	var q Quadruple
+	if x.Signbit() {
+		q.Hi = 1 << 63
+	}
+
+	switch {
+	case x.IsInf():
+		q.Hi |= expMask << (fracBits - 64)
+		return q
+	case x.Sign() == 0:
+		return q
+	}
+
+	// |x| = mant * 2^e with 0.5 <= mant < 1, so 2^(e-1) <= |x| < 2^e
+	abs := new(big.Float).Abs(x)
+	e := abs.MantExp(nil) - 1
+
+	// Determine how many bits of precision we have at this exponent, and round
+	// to that many
+	prec := precision
+	if e < minExp {
+		prec = e - minSubnormalExp + 1
+	}
+
+	if prec <= 0 {
+		// Smaller than the smallest subnormal. Rounds to it if more than half
+		// of it (a tie rounds to even, i.e. zero)
+		half := new(big.Float).SetMantExp(big.NewFloat(1), minSubnormalExp-1)
+		if abs.Cmp(half) > 0 {
+			q.Lo = 1
+		}
+		return q
+	}
+
+	r := new(big.Float).SetMode(big.ToNearestEven).SetPrec(uint(prec)).Set(abs)
+
+	// Rounding may have carried into the next power of two
+	e = r.MantExp(nil) - 1
+	if e > bias {
+		q.Hi |= expMask << (fracBits - 64)
+		return q
+	}
+
+	var (
+		exp   uint64
+		shift int
+	)
+	if e < minExp {
+		// Subnormal
+		shift = -minSubnormalExp
+	} else {
+		exp = uint64(e + bias)
+		shift = fracBits - e
+	}
+
+	sig, _ := r.SetMantExp(r, shift).Int(nil)
+	sig.SetBit(sig, fracBits, 0)
+
+	var lo big.Int
+	lo.SetBit(&lo, 64, 1).Sub(&lo, big.NewInt(1)).And(&lo, sig)
+	q.Lo = lo.Uint64()
+	q.Hi |= exp<<(fracBits-64) | new(big.Int).Rsh(sig, 64).Uint64()
+	return q
+}
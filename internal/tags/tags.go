@@ -6,6 +6,7 @@ package tags
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -68,6 +69,18 @@ const (
 	// Indicates that this field (which must be a member of a union) is used when the union discriminant
 	// has an otherwise unspecified value
 	UnionDefault
+	// Indicates that this field (which must be an int, uint or uintptr) is to be encoded as a 32-bit
+	// XDR int or unsigned int
+	Int
+	// Indicates that this field (which must be an int, uint or uintptr) is to be encoded as a 64-bit
+	// XDR hyper or unsigned hyper. This is the default for these types
+	Hyper
+	// Indicates that this field (which must be an 8 or 16 bit integer) should be truncated
+	// (rather than causing an error) when a value out of its range is decoded
+	Wrap
+	// Like UnionSwitch, but additionally the discriminant is inferred from the populated arm
+	// when encoding
+	UnionSwitchInfer
 
 	// Kinds with single value, starting at 0x80 (0b10xx_xxxx)
 
@@ -79,11 +92,22 @@ const (
 	// length array with length of up to the amount that follows
 	MaxLen
 
+	// Specifies that this field (which must be a slice) is to be encoded as a linked list
+	// of optional data (i.e. as each element preceded by TRUE, followed by a final FALSE),
+	// with length of up to the amount that follows
+	OptList
+
 	// Kinds with multiple values, starting 0xC0 (0b11xx_xxxx)
 
 	// Specifies that this field (which must be a member of a union) is used when the union discriminant
-	// has any of the specified values
+	// has any of the specified values. Each value is a signed 64-bit integer, encoded as a pair of
+	// 32-bit values (most significant first); see AppendCases and Cases
 	UnionCases = 0xC0 | iota
+
+	// Specifies the maximum length of this field (which must be a map) and the tag to be applied
+	// to its keys. The first value is the maximum length; each following value holds one byte of
+	// the encoded key tag
+	MapKey
 )
 
 // Empty returns if this tag is empty
@@ -167,6 +191,36 @@ func (t XDRTag) Value(n int) uint32 {
 	return t.valAt(1 + 4*n)
 }
 
+// AppendCases appends a UnionCases tag with the specified case labels
+func (t XDRTag) AppendCases(labels ...int64) XDRTag {
+	vals := make([]uint32, 0, 2*len(labels))
+	for _, l := range labels {
+		vals = append(vals, uint32(uint64(l)>>32), uint32(l))
+	}
+	return t.Append(UnionCases, vals...)
+}
+
+// Cases returns the case labels of a UnionCases tag
+func (t XDRTag) Cases() []int64 {
+	var labels []int64
+	for i, n := t.ValueRange(); i+1 < n; i += 2 {
+		labels = append(labels, int64(uint64(t.Value(i))<<32|uint64(t.Value(i+1))))
+	}
+	return labels
+}
+
+// KeyTag returns the maximum length and the key tag of a MapKey tag
+func (t XDRTag) KeyTag() (uint32, XDRTag) {
+	i, n := t.ValueRange()
+	max := t.Value(i)
+
+	var kt XDRTag
+	for i++; i < n; i++ {
+		kt = append(kt, byte(t.Value(i)))
+	}
+	return max, kt
+}
+
 // Appends a tag with the specified values to the end of the current tag set
 func (t XDRTag) Append(k XDRTagKind, values ...uint32) XDRTag {
 	switch {
@@ -225,6 +279,21 @@ func (t XDRTag) Trimmed() XDRTag {
 	return XDRTag(t[0:mark])
 }
 
+// Merge returns this tag with any Noop (or missing) entries replaced by the
+// corresponding entries of def
+func (t XDRTag) Merge(def XDRTag) XDRTag {
+	var nt XDRTag
+	for !t.Empty() || !def.Empty() {
+		if t.Empty() || (t.Kind() == Noop && !def.Empty()) {
+			nt = append(nt, def[0:def.thisLen()]...)
+		} else {
+			nt = append(nt, t[0:t.thisLen()]...)
+		}
+		t, def = t.Next(), def.Next()
+	}
+	return nt.Trimmed()
+}
+
 // Returns this tag list as a byte slice. It must not be modified
 func (t XDRTag) Bytes() []byte {
 	return []byte(t)
@@ -277,7 +346,9 @@ func validForUnionSwitch(t reflect.Type) bool {
 		reflect.Uint,
 		reflect.Uint8,
 		reflect.Uint16,
-		reflect.Uint32:
+		reflect.Uint32,
+		reflect.Uint64,
+		reflect.Uintptr:
 		return true
 
 	default:
@@ -309,13 +380,18 @@ const (
 	InUnion
 )
 
+// ConstantResolver looks up the value of a named constant used in a tag. It
+// returns false if the constant is undefined
+type ConstantResolver func(name string) (int64, bool)
+
 // Parse a struct tag to be applied to the specified type
 func ParseStructTag(
 	t reflect.Type,
 	rtag reflect.StructTag,
 	isUnion *IsInUnion,
+	consts ConstantResolver,
 ) (XDRTag, error) {
-	return ParseTag(t, rtag.Get("xdr"), isUnion)
+	return ParseTag(t, rtag.Get("xdr"), isUnion, consts)
 }
 
 func parseU32(s string) (uint32, error) {
@@ -323,17 +399,75 @@ func parseU32(s string) (uint32, error) {
 	return uint32(u64), err
 }
 
-func parseU32s(s string) ([]uint32, error) {
+// isIdentifier returns whether s looks like the name of a constant (rather than a number)
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseCase parses a union case label. Labels may be signed (e.g. "-1"), or the name
+// of a constant resolved using consts. They are checked against the range of any 32-bit
+// switch here; whether they are in range for the actual switch type is checked when the
+// union's codec is constructed
+func parseCase(s string, consts ConstantResolver) (int64, error) {
+	var (
+		i64 int64
+		err error
+	)
+
+	if isIdentifier(s) {
+		var ok bool
+		if consts != nil {
+			i64, ok = consts(s)
+		}
+		if !ok {
+			return 0, fmt.Errorf("Undefined constant '%s'", s)
+		}
+	} else if i64, err = strconv.ParseInt(s, 0, 64); err != nil {
+		return 0, err
+	}
+
+	if i64 < math.MinInt32 || i64 > math.MaxUint32 {
+		return 0, fmt.Errorf("Union case %s (%d) out of range", s, i64)
+	}
+	return i64, nil
+}
+
+func parseCases(s string, consts ConstantResolver) ([]int64, error) {
 	vals := strings.Split(s, ",")
-	u32s := make([]uint32, 0, len(vals))
+	labels := make([]int64, 0, len(vals))
 	for _, v := range vals {
-		u32, err := parseU32(v)
+		label, err := parseCase(strings.TrimSpace(v), consts)
 		if err != nil {
 			return nil, err
 		}
-		u32s = append(u32s, u32)
+		labels = append(labels, label)
 	}
-	return u32s, nil
+	return labels, nil
+}
+
+// parseKeyTag parses the tag of a map key type
+func parseKeyTag(t reflect.Type, s string, consts ConstantResolver) (XDRTag, error) {
+	isUnion := NotInUnion
+	kt, err := ParseTag(t, s, &isUnion, consts)
+	if err != nil {
+		return nil, err
+	}
+	if kt.Kind() == Skip {
+		return nil, errors.New("Map keys cannot be skipped")
+	}
+	return kt, nil
 }
 
 // Parses the body of an XDR tag
@@ -341,6 +475,7 @@ func ParseTag(
 	t reflect.Type,
 	stags string,
 	isUnion *IsInUnion,
+	consts ConstantResolver,
 ) (
 	xt XDRTag,
 	err error,
@@ -362,7 +497,7 @@ func ParseTag(
 		parts = parts[1:]
 
 		switch {
-		case p == "union:switch":
+		case p == "union:switch" || p == "union:switch,infer":
 			if *isUnion != MaybeInUnion {
 				return xt, errors.New("Found field annotated with `union:switch` tag which is not legal in a struct which is not a union or already has a switch")
 			}
@@ -372,24 +507,28 @@ func ParseTag(
 			}
 
 			*isUnion = InUnion
-			xt = xt.Append(UnionSwitch)
+			if p == "union:switch" {
+				xt = xt.Append(UnionSwitch)
+			} else {
+				xt = xt.Append(UnionSwitchInfer)
+			}
 
 		case *isUnion != InUnion:
 			return xt, fmt.Errorf("'%s' union tag not valid as we are not inside a union", p)
 
 		case p == "union:false":
-			xt = xt.Append(UnionCases, 0)
+			xt = xt.AppendCases(0)
 		case p == "union:true":
-			xt = xt.Append(UnionCases, 1)
+			xt = xt.AppendCases(1)
 		case p == "union:default":
 			xt = xt.Append(UnionDefault)
 		default:
-			vals, err := parseU32s(strings.TrimPrefix(p, "union:"))
+			vals, err := parseCases(strings.TrimPrefix(p, "union:"), consts)
 			if err != nil {
 				return xt, fmt.Errorf("Parsing `union:` values: %v", err)
 			}
 
-			xt = xt.Append(UnionCases, vals...)
+			xt = xt.AppendCases(vals...)
 		}
 	} else if *isUnion == InUnion {
 		return xt, errors.New("Every field inside a union struct must have a `union:` leading tag")
@@ -401,6 +540,29 @@ func ParseTag(
 	// types
 	for i, n := 0, len(parts); i < n; i++ {
 		p := strings.TrimSpace(parts[i])
+
+		// A `key=` part immediately following the part for a map specifies the tag of the
+		// map's key type. It doesn't correspond to a layer, so we fold it into the map's
+		// entry below. (If the map has no other tag, the `key=` part may come first)
+		if t.Kind() == reflect.Map && strings.HasPrefix(p, "key=") {
+			parts = append(parts[:i], append([]string{""}, parts[i:]...)...)
+			n++
+			p = ""
+		}
+
+		var keyTag XDRTag
+		if t.Kind() == reflect.Map && i+1 < n && strings.HasPrefix(strings.TrimSpace(parts[i+1]), "key=") {
+			kt, err := parseKeyTag(t.Key(), strings.TrimSpace(parts[i+1])[4:], consts)
+			if err != nil {
+				return xt, fmt.Errorf("Parsing `key=` tag: %v", err)
+			}
+
+			keyTag = kt
+			parts = append(parts[:i+1], parts[i+2:]...)
+			n--
+		}
+
+		start := len(xt)
 		switch {
 		case p == "":
 			xt = xt.Append(Noop)
@@ -429,6 +591,26 @@ func ParseTag(
 				return xt, fmt.Errorf("'opaque' label applied to %s, but only applicable to bytes", t)
 			}
 
+		case p == "int" || p == "hyper":
+			switch t.Kind() {
+			case reflect.Int, reflect.Uint, reflect.Uintptr:
+				if p == "int" {
+					xt = xt.Append(Int)
+				} else {
+					xt = xt.Append(Hyper)
+				}
+			default:
+				return xt, fmt.Errorf("'%s' label applied to %s, but only applicable to int, uint or uintptr", p, t)
+			}
+
+		case p == "wrap":
+			switch t.Kind() {
+			case reflect.Int8, reflect.Int16, reflect.Uint8, reflect.Uint16:
+				xt = xt.Append(Wrap)
+			default:
+				return xt, fmt.Errorf("'wrap' label applied to %s, but only applicable to 8 and 16 bit integers", t)
+			}
+
 		case strings.HasPrefix(p, "len:"):
 			len, err := parseU32(p[4:])
 			if err != nil {
@@ -444,6 +626,22 @@ func ParseTag(
 				return xt, fmt.Errorf("Cannot apply `len:` tag to %s; must be slice, string or map", t)
 			}
 
+		case p == "optlist" || strings.HasPrefix(p, "optlist:"):
+			max := ^uint32(0)
+			if p != "optlist" {
+				var err error
+				if max, err = parseU32(p[8:]); err != nil {
+					return xt, fmt.Errorf("Error parsing XDR `optlist:` tag: %v", err)
+				}
+			}
+
+			switch t.Kind() {
+			case reflect.Slice:
+				xt = xt.Append(OptList, max)
+			default:
+				return xt, fmt.Errorf("Cannot apply `optlist` tag to %s; must be slice", t)
+			}
+
 		case strings.HasPrefix(p, "maxlen:"):
 			len, err := parseU32(p[7:])
 			if err != nil {
@@ -451,16 +649,37 @@ func ParseTag(
 			}
 
 			switch t.Kind() {
-			case reflect.String, reflect.Slice:
+			case reflect.String, reflect.Slice, reflect.Map:
 				xt = xt.Append(MaxLen, len)
 			default:
 				return xt, fmt.Errorf("Cannot apply `maxlen:` tag to %s; must be slice, string or map", t)
 			}
 
+		case strings.HasPrefix(p, "key="):
+			return xt, fmt.Errorf("`key=` tag applied to %s, but must immediately follow the tag of a map", t)
+
 		default:
 			return xt, fmt.Errorf("Unknown XDR tag '%s'", p)
 		}
 
+		if !keyTag.Empty() {
+			max := ^uint32(0)
+			switch e := xt[start:]; e.Kind() {
+			case MaxLen:
+				max = e.OnlyValue()
+			case Noop:
+				// Nothing
+			default:
+				return xt, fmt.Errorf("`key=` tag cannot be combined with '%s'", p)
+			}
+
+			vals := []uint32{max}
+			for _, b := range keyTag {
+				vals = append(vals, uint32(b))
+			}
+			xt = xt[:start].Append(MapKey, vals...)
+		}
+
 		// Descend one level through the types
 		if i+1 != n {
 			switch t.Kind() {
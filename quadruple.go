@@ -0,0 +1,43 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package xdr
+
+import (
+	"math/big"
+
+	"go.e43.eu/xdr/internal/quadruple"
+)
+
+// Quadruple is an IEEE 754 binary128 (quadruple precision) floating point number,
+// which is the XDR `quadruple` type. Hi holds the sign bit, exponent and most
+// significant 48 bits of the fraction; Lo holds the rest of the fraction.
+//
+// Go has no native quadruple precision type; use QuadrupleFromFloat64 and
+// QuadrupleFromBigFloat to construct them, and the Float64 and BigFloat methods
+// to convert them back
+type Quadruple = quadruple.Quadruple
+
+// QuadrupleFromFloat64 returns f as a Quadruple. This conversion is exact.
+//
+// NaNs are converted to NaNs, preserving the sign and payload
+func QuadrupleFromFloat64(f float64) Quadruple {
+	return quadruple.FromFloat64(f)
+}
+
+// QuadrupleFromBigFloat returns the Quadruple nearest to x, rounding ties to even.
+// Values too large in magnitude become infinities, and values too small become
+// zero (or subnormals) of the same sign
+func QuadrupleFromBigFloat(x *big.Float) Quadruple {
+	return quadruple.FromBigFloat(x)
+}
+
+// QuadrupleInf returns positive infinity if sign >= 0, negative infinity if sign < 0
+func QuadrupleInf(sign int) Quadruple {
+	return quadruple.Inf(sign)
+}
+
+// QuadrupleNaN returns a (quiet) NaN
+func QuadrupleNaN() Quadruple {
+	return quadruple.NaN()
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Erin Shepherd
+// SPDX-License-Identifier: ISC
+
+package xdr
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type skipFixed struct {
+	A uint32
+	B int64
+	C [3]byte `xdr:"opaque"`
+}
+
+type skipUnion struct {
+	Kind uint32      `xdr:"union:switch"`
+	S    string      `xdr:"union:1"`
+	F    *skipFixed  `xdr:"union:2/opt"`
+	L    []skipFixed `xdr:"union:3"`
+}
+
+type skipVar struct {
+	Name  string
+	Data  []byte `xdr:"opaque"`
+	Fixed skipFixed
+	U     []skipUnion
+	M     map[string]uint32
+	P     *skipVar `xdr:"opt"`
+}
+
+// skipMarshaler skips a skipVar, then reads a uint32 into *tail
+type skipMarshaler struct {
+	tail *uint32
+}
+
+func (m skipMarshaler) MarshalXDR(e Encoder) error {
+	return ErrInvalidValue
+}
+
+func (m skipMarshaler) UnmarshalXDR(d Decoder) error {
+	if err := d.Skip(reflect.TypeOf(skipVar{})); err != nil {
+		return err
+	}
+	return d.Decode(m.tail)
+}
+
+func TestSkip(t *testing.T) {
+	v := skipVar{
+		Name:  "hello",
+		Data:  []byte{1, 2, 3, 4, 5},
+		Fixed: skipFixed{1, 2, [3]byte{3, 4, 5}},
+		U: []skipUnion{
+			{Kind: 1, S: "abc"},
+			{Kind: 2, F: &skipFixed{A: 7}},
+			{Kind: 2},
+			{Kind: 3, L: []skipFixed{{}, {}}},
+		},
+		M: map[string]uint32{"a": 1, "bb": 2},
+		P: &skipVar{Name: "inner"},
+	}
+
+	buf, err := Marshal(&v)
+	require.NoError(t, err)
+	buf = append(buf, 0, 0, 0, 42)
+
+	check := func(t *testing.T, d Decoder) {
+		require.NoError(t, d.Skip(reflect.TypeOf(skipVar{})))
+		assert.Equal(t, int64(len(buf)-4), d.Offset())
+
+		var tail uint32
+		require.NoError(t, d.Decode(&tail))
+		assert.Equal(t, uint32(42), tail)
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		check(t, NewDecoder(bytes.NewReader(buf)))
+	})
+
+	t.Run("Buffer", func(t *testing.T) {
+		var tail uint32
+		m := skipMarshaler{&tail}
+		require.NoError(t, Unmarshal(buf, &m))
+		assert.Equal(t, uint32(42), tail)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		d := NewDecoder(bytes.NewReader(buf[0 : len(buf)-12]))
+		assert.Equal(t, io.ErrUnexpectedEOF, unwrapAll(d.Skip(reflect.TypeOf(skipVar{}))))
+
+		d = NewDecoder(bytes.NewReader(nil))
+		assert.Equal(t, io.EOF, d.Skip(reflect.TypeOf(skipFixed{})))
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		c := NewCoder()
+		c.SetStrict(true)
+		d := c.NewDecoder(bytes.NewReader([]byte{0, 0, 0, 1, 'a', 1, 0, 0}))
+		assert.Equal(t, ErrNonZeroPadding, d.Skip(reflect.TypeOf("")))
+
+		// skipFixed is of fixed size, but its padding must still be checked
+		badPad := []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2, 3, 4, 5, 1}
+		d = c.NewDecoder(bytes.NewReader(badPad))
+		assert.Equal(t, ErrNonZeroPadding, unwrapAll(d.Skip(reflect.TypeOf(skipFixed{}))))
+
+		d = c.NewDecoder(bytes.NewReader(badPad))
+		assert.Equal(t, ErrNonZeroPadding, unwrapAll(d.Skip(reflect.TypeOf([2]skipFixed{}))))
+
+		d = NewDecoder(bytes.NewReader(badPad))
+		assert.NoError(t, d.Skip(reflect.TypeOf(skipFixed{})))
+	})
+}
+
+func unwrapAll(err error) error {
+	for {
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return err
+		}
+		err = u.Unwrap()
+	}
+}
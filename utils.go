@@ -23,10 +23,47 @@ func (d *defaultCoder) RegisterCodecReflect(type_ reflect.Type, c xdrinterfaces.
 	panic("Cannot register type on default codec")
 }
 
+func (d *defaultCoder) RegisterConstants(consts map[string]int64) {
+	panic("Cannot register constants on default codec")
+}
+
+func (d *defaultCoder) RegisterEnum(template interface{}, values map[string]int32) {
+	panic("Cannot register enum on default codec")
+}
+
+func (d *defaultCoder) RegisterEnumReflect(type_ reflect.Type, values map[string]int32) {
+	panic("Cannot register enum on default codec")
+}
+
+func (d *defaultCoder) RegisterInterface(template interface{}, arms map[int32]interface{}) {
+	panic("Cannot register interface on default codec")
+}
+
+func (d *defaultCoder) RegisterInterfaceReflect(type_ reflect.Type, arms map[int32]reflect.Type) {
+	panic("Cannot register interface on default codec")
+}
+
+func (d *defaultCoder) SetAllowUnknownEnums(allow bool) {
+	panic("Cannot set unknown enum mode on default codec")
+}
+
+func (d *defaultCoder) SetLimits(l Limits) {
+	panic("Cannot set limits on default codec")
+}
+
+func (d *defaultCoder) SetStrict(strict bool) {
+	panic("Cannot set strict mode on default codec")
+}
+
+func (d *defaultCoder) SetSortedMaps(sorted bool) {
+	panic("Cannot set sorted maps mode on default codec")
+}
+
 // The default coder (used by the package global functions)
 //
 // This behaves identically to a coder created using NewCoder, except
-// that it is not permitted to register any codecs upon it.
+// that it is not permitted to register any codecs upon it or to change
+// its settings.
 var DefaultCoder defaultCoder
 
 // Marshal marshals o into the returned buffer using DefaultCoder
@@ -34,11 +71,28 @@ func Marshal(o interface{}) ([]byte, error) {
 	return DefaultCoder.Marshal(o)
 }
 
+// MarshalAppend marshals o onto the end of dst using DefaultCoder, returning the
+// extended buffer. See Coder.MarshalAppend
+func MarshalAppend(dst []byte, o interface{}) ([]byte, error) {
+	return DefaultCoder.MarshalAppend(dst, o)
+}
+
+// EncodedSize returns the encoded size of o using DefaultCoder. See Coder.EncodedSize
+func EncodedSize(o interface{}) (int, error) {
+	return DefaultCoder.EncodedSize(o)
+}
+
 // Unmarshal unmarshals buf into the object pointed to by op using DefaultCoder
 func Unmarshal(buf []byte, op interface{}) error {
 	return DefaultCoder.Unmarshal(buf, op)
 }
 
+// UnmarshalNoCopy unmarshals buf into the object pointed to by op using DefaultCoder,
+// without copying opaque data or strings out of buf. See Coder.UnmarshalNoCopy
+func UnmarshalNoCopy(buf []byte, op interface{}) error {
+	return DefaultCoder.UnmarshalNoCopy(buf, op)
+}
+
 // Write marshals o into the passed writer using DefaultCoder
 func Write(w io.Writer, o interface{}) error {
 	return DefaultCoder.Write(w, o)